@@ -0,0 +1,502 @@
+// Package config defines the configuration file of the exporter.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProjectLabel is the label that carries the name of the project on every metric.
+	ProjectLabel = "project"
+	// DefaultProjectName is the name of the project that is created from a plain API token.
+	DefaultProjectName = "default"
+
+	defaultPort          = 8080
+	defaultFetchInterval = 1 * time.Minute
+	defaultFetchTimeout  = 30 * time.Second
+	defaultConcurrency   = 4
+	defaultReserve       = 100
+	defaultReloadWatch   = 30 * time.Second
+)
+
+var (
+	projectNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	currencyPattern    = regexp.MustCompile(`^[A-Z]{3}$`)
+	labelNamePattern   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Config is the root of the configuration file.
+type Config struct {
+	// Port is the port that the exporter exposes its data on.
+	Port uint `yaml:"port"`
+	// AdditionalLabels lists the resource labels that are exposed as metric labels for all projects.
+	AdditionalLabels []string `yaml:"additional_labels"`
+	// Fetch controls the data fetching cycles.
+	Fetch Fetch `yaml:"fetch"`
+	// Pricing controls how the pricing information is cached.
+	Pricing Pricing `yaml:"pricing"`
+	// Readiness controls when the exporter reports as ready.
+	Readiness Readiness `yaml:"readiness"`
+	// Fetchers selects the fetchers that run for all projects.
+	Fetchers Fetchers `yaml:"fetchers"`
+	// RateLimit controls how the exporter deals with the request budget of the HCloud API.
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Reload controls how changes to the config file and token files are picked up.
+	Reload Reload `yaml:"reload"`
+	// StateDir is the directory that keeps the last successful API responses across restarts. Empty disables it.
+	StateDir string `yaml:"state_dir"`
+	// Projects lists the HCloud projects that are monitored by the exporter.
+	Projects []Project `yaml:"projects"`
+}
+
+// MaxAgeOf returns the maximum age of the last successful fetch of the passed fetcher. Fetchers that run less often
+// than the default interval get three of their own intervals at least.
+func (config *Config) MaxAgeOf(name string) time.Duration {
+	return max(config.Readiness.MaxAge, 3*config.Fetch.IntervalOf(name))
+}
+
+// Fetch controls the data fetching cycles.
+type Fetch struct {
+	// Interval is the interval between data fetching cycles.
+	Interval time.Duration `yaml:"interval"`
+	// Intervals overrides the interval for single fetchers by their name.
+	Intervals map[string]time.Duration `yaml:"intervals"`
+	// Timeout is the maximum duration of a single fetcher. Zero disables the timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// CycleTimeout is the maximum duration of a whole data fetching cycle. Zero defaults to the interval.
+	CycleTimeout time.Duration `yaml:"cycle_timeout"`
+	// Concurrency is the maximum number of fetchers that run in parallel. Zero runs all of them at once.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// IntervalOf returns the interval between the data fetching cycles of the passed fetcher.
+func (fetch Fetch) IntervalOf(name string) time.Duration {
+	if interval, ok := fetch.Intervals[name]; ok {
+		return interval
+	}
+	return fetch.Interval
+}
+
+// Pricing controls how the pricing information is cached.
+type Pricing struct {
+	// RefreshInterval is the interval in which the cached pricing is refreshed. Zero defaults to ten fetch intervals.
+	// Independent of it, the pricing is refreshed on the first fetch of every calendar month.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// File is a local file in the shape of the response of the /pricing endpoint, which replaces the API as the source
+	// of pricing information. It is read again whenever it changes. Empty uses the API.
+	File string `yaml:"file"`
+	// Overrides is a local file in the same shape, whose prices replace the ones of the API or the pricing file.
+	Overrides string `yaml:"overrides"`
+	// PriceType selects whether net prices, gross prices or both are exposed.
+	PriceType fetcher.PriceType `yaml:"price_type"`
+	// Currency is the ISO 4217 code of the currency that all prices are converted to. Empty keeps the currency of the
+	// pricing information.
+	Currency string `yaml:"currency"`
+	// RatesFile is an XML or CSV export of the reference rates of the European Central Bank, which is used for the
+	// conversion to Currency. It is read again whenever it changes.
+	RatesFile string `yaml:"rates_file"`
+	// VATRate replaces the VAT rate of the pricing information in percent, if set, e.g. with zero for reverse charge.
+	VATRate *float64 `yaml:"vat_rate"`
+	// Adjustments change the prices of the resources they match, in the order they are listed.
+	Adjustments []Adjustment `yaml:"adjustments"`
+}
+
+// Adjustment changes the prices of the resources that it matches, e.g. for a discount or a markup. Empty criteria match
+// every resource.
+type Adjustment struct {
+	// Resource is the name of the fetcher whose resources are matched.
+	Resource string `yaml:"resource"`
+	// ServerType is the name of the server type of the matched servers, their backups and their traffic.
+	ServerType string `yaml:"server_type"`
+	// Location is the name of the location of the matched resources.
+	Location string `yaml:"location"`
+	// Labels must all be set to the same values on the matched resources.
+	Labels map[string]string `yaml:"labels"`
+	// Percent changes the prices relatively, e.g. -10 for a discount of ten percent.
+	Percent float64 `yaml:"percent"`
+	// Amount changes the monthly net price absolutely in the exposed currency, after Percent is applied. It only applies
+	// to the backups and the traffic of servers and load balancers, if Resource names them.
+	Amount float64 `yaml:"amount"`
+}
+
+// Readiness controls when the exporter reports as ready.
+type Readiness struct {
+	// MaxAge is the maximum age of the last successful fetch. Zero defaults to three fetch intervals.
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Fetchers selects fetchers by the name of the resource type they collect costs for.
+type Fetchers struct {
+	// Enabled lists the fetchers that run. If it is empty, all fetchers run.
+	Enabled []string `yaml:"enabled"`
+	// Disabled lists the fetchers that do not run, even if they are enabled.
+	Disabled []string `yaml:"disabled"`
+}
+
+// Names returns the names of the selected fetchers.
+func (fetchers Fetchers) Names() ([]string, error) {
+	return fetcher.Select(fetchers.Enabled, fetchers.Disabled)
+}
+
+// RateLimit controls how the exporter deals with the request budget of the HCloud API.
+type RateLimit struct {
+	// Reserve is the number of remaining requests below which the request budget is considered to run low.
+	Reserve int `yaml:"reserve"`
+	// OptionalFetchers lists the fetchers that are skipped while the request budget runs low.
+	OptionalFetchers []string `yaml:"optional_fetchers"`
+}
+
+// Reload controls how changes to the config file and token files are picked up.
+type Reload struct {
+	// Interval is the interval in which the files are checked for changes. Zero disables the check, so that only
+	// SIGHUP triggers a reload.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Project defines a single HCloud project and where to find its API token.
+type Project struct {
+	// Name identifies the project and is exposed as the project label of every metric.
+	Name string `yaml:"name"`
+	// Token is the API token of the project.
+	Token string `yaml:"token"`
+	// TokenEnv is the name of an environment variable that contains the API token of the project.
+	TokenEnv string `yaml:"token_env"`
+	// TokenFile is the path of a file that contains the API token of the project, e.g. a mounted secret.
+	TokenFile string `yaml:"token_file"`
+	// AllowWorldReadableTokenFile accepts a token file that can be read by every user.
+	AllowWorldReadableTokenFile bool `yaml:"allow_world_readable_token_file"`
+	// AdditionalLabels replaces the global additional labels for this project, if set.
+	AdditionalLabels []string `yaml:"additional_labels"`
+	// Fetchers replaces the global selection of fetchers for this project, if set.
+	Fetchers *Fetchers `yaml:"fetchers"`
+	// VATRate replaces the global VAT rate for this project in percent, if set, e.g. with zero for reverse charge.
+	VATRate *float64 `yaml:"vat_rate"`
+}
+
+// Default returns the configuration that is used, if neither a config file nor flags say otherwise.
+func Default() *Config {
+	return &Config{
+		Port: defaultPort,
+		Fetch: Fetch{
+			Interval:    defaultFetchInterval,
+			Timeout:     defaultFetchTimeout,
+			Concurrency: defaultConcurrency,
+		},
+		Pricing: Pricing{
+			PriceType: fetcher.PriceTypeGross,
+		},
+		RateLimit: RateLimit{
+			Reserve:          defaultReserve,
+			OptionalFetchers: []string{"snapshot"},
+		},
+		Reload: Reload{
+			Interval: defaultReloadWatch,
+		},
+	}
+}
+
+// Load reads the configuration file at the passed path on top of the default configuration. YAML and JSON files are
+// supported, unknown keys are rejected.
+func Load(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+
+	config := Default()
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Finalize derives the values that default to other values and validates the resulting configuration.
+func (config *Config) Finalize() error {
+	// An invalid interval is reported by the validation, it should not be reported again for the derived values.
+	if config.Fetch.Interval > 0 {
+		if config.Fetch.CycleTimeout == 0 {
+			config.Fetch.CycleTimeout = config.Fetch.Interval
+		}
+		if config.Readiness.MaxAge == 0 {
+			config.Readiness.MaxAge = 3 * config.Fetch.Interval
+		}
+		if config.Pricing.RefreshInterval == 0 {
+			config.Pricing.RefreshInterval = 10 * config.Fetch.Interval
+		}
+	}
+
+	return config.Validate()
+}
+
+// Validate checks the configuration for missing or contradicting values. The returned error names the offending key.
+func (config *Config) Validate() error {
+	var errs []error
+
+	if config.Port == 0 || config.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port: %d is not a valid port", config.Port))
+	}
+	errs = append(errs, validateFetch("fetch", config.Fetch)...)
+	errs = append(errs, validatePricing("pricing", config.Pricing)...)
+	if config.Readiness.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("readiness.max_age: %s must not be negative", config.Readiness.MaxAge))
+	}
+	if config.RateLimit.Reserve < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.reserve: %d must not be negative", config.RateLimit.Reserve))
+	}
+	if config.Reload.Interval < 0 {
+		errs = append(errs, fmt.Errorf("reload.interval: %s must not be negative", config.Reload.Interval))
+	}
+	errs = append(errs, validateLabels("additional_labels", config.AdditionalLabels)...)
+	errs = append(errs, validateFetchers("fetchers", config.Fetchers)...)
+	errs = append(errs, validateFetcherNames("rate_limit.optional_fetchers", config.RateLimit.OptionalFetchers)...)
+	errs = append(errs, validateProjects("projects", config.Projects)...)
+
+	return errors.Join(errs...)
+}
+
+func validateFetch(path string, fetch Fetch) (errs []error) {
+	if fetch.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: %s must be positive", path, fetch.Interval))
+	}
+	for _, name := range slices.Sorted(maps.Keys(fetch.Intervals)) {
+		intervalPath := fmt.Sprintf("%s.intervals.%s", path, name)
+		if !fetcher.Known(name) {
+			errs = append(errs, fmt.Errorf("%s: unknown fetcher %q, expected one of %v", intervalPath, name, fetcher.Names()))
+		} else if fetch.Intervals[name] <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s must be positive", intervalPath, fetch.Intervals[name]))
+		}
+	}
+	if fetch.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: %s must not be negative", path, fetch.Timeout))
+	}
+	if fetch.CycleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.cycle_timeout: %s must not be negative", path, fetch.CycleTimeout))
+	}
+	if fetch.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("%s.concurrency: %d must not be negative", path, fetch.Concurrency))
+	}
+	return errs
+}
+
+func validatePricing(path string, pricing Pricing) (errs []error) {
+	if pricing.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s.refresh_interval: %s must not be negative", path, pricing.RefreshInterval))
+	}
+	if !slices.Contains(fetcher.PriceTypes(), string(pricing.PriceType)) {
+		errs = append(errs, fmt.Errorf("%s.price_type: unknown price type %q, expected one of %v",
+			path, pricing.PriceType, fetcher.PriceTypes()))
+	}
+	switch {
+	case pricing.Currency != "" && !currencyPattern.MatchString(pricing.Currency):
+		errs = append(errs, fmt.Errorf("%s.currency: %q is not a currency code like USD", path, pricing.Currency))
+	case pricing.Currency != "" && pricing.Currency != "EUR" && pricing.RatesFile == "":
+		errs = append(errs, fmt.Errorf("%s.currency: converting prices to %s requires %s.rates_file",
+			path, pricing.Currency, path))
+	}
+	errs = append(errs, validateVATRate(path+".vat_rate", pricing.VATRate)...)
+	for i, adjustment := range pricing.Adjustments {
+		errs = append(errs, validateAdjustment(fmt.Sprintf("%s.adjustments[%d]", path, i), adjustment)...)
+	}
+	return errs
+}
+
+func validateProjects(path string, projects []Project) (errs []error) {
+	if len(projects) == 0 {
+		errs = append(errs, fmt.Errorf("%s: at least one project is required", path))
+	}
+
+	names := map[string]bool{}
+	for i, project := range projects {
+		projectPath := fmt.Sprintf("%s[%d]", path, i)
+
+		if !projectNamePattern.MatchString(project.Name) {
+			errs = append(errs, fmt.Errorf("%s.name: %q must only contain letters, digits, '_' and '-'",
+				projectPath, project.Name))
+		} else if names[project.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: %q is used by more than one project", projectPath, project.Name))
+		}
+		names[project.Name] = true
+
+		if countSet(project.Token, project.TokenEnv, project.TokenFile) != 1 {
+			errs = append(errs, fmt.Errorf("%s: exactly one of token, token_env and token_file is required", projectPath))
+		}
+		errs = append(errs, validateLabels(projectPath+".additional_labels", project.AdditionalLabels)...)
+		if project.Fetchers != nil {
+			errs = append(errs, validateFetchers(projectPath+".fetchers", *project.Fetchers)...)
+		}
+		errs = append(errs, validateVATRate(projectPath+".vat_rate", project.VATRate)...)
+	}
+
+	return errs
+}
+
+func validateVATRate(path string, rate *float64) []error {
+	if rate != nil && (*rate < 0 || *rate > 100) {
+		return []error{fmt.Errorf("%s: %g must be between 0 and 100", path, *rate)}
+	}
+	return nil
+}
+
+func validateAdjustment(path string, adjustment Adjustment) (errs []error) {
+	if adjustment.Resource != "" && !fetcher.Known(adjustment.Resource) {
+		errs = append(errs, fmt.Errorf("%s.resource: unknown fetcher %q, expected one of %v",
+			path, adjustment.Resource, fetcher.Names()))
+	}
+	if adjustment.Percent < -100 {
+		errs = append(errs, fmt.Errorf("%s.percent: %g must not be below -100", path, adjustment.Percent))
+	}
+	if adjustment.Percent == 0 && adjustment.Amount == 0 {
+		errs = append(errs, fmt.Errorf("%s: one of percent and amount is required", path))
+	}
+	return errs
+}
+
+func countSet(values ...string) (count int) {
+	for _, value := range values {
+		if value != "" {
+			count++
+		}
+	}
+	return count
+}
+
+func validateFetchers(path string, fetchers Fetchers) []error {
+	errs := validateFetcherNames(path+".enabled", fetchers.Enabled)
+	errs = append(errs, validateFetcherNames(path+".disabled", fetchers.Disabled)...)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if names, _ := fetchers.Names(); len(names) == 0 {
+		errs = append(errs, fmt.Errorf("%s: at least one fetcher must be enabled", path))
+	}
+	return errs
+}
+
+func validateFetcherNames(path string, names []string) (errs []error) {
+	for i, name := range names {
+		if !fetcher.Known(name) {
+			errs = append(errs, fmt.Errorf("%s[%d]: unknown fetcher %q, expected one of %v", path, i, name, fetcher.Names()))
+		}
+	}
+	return errs
+}
+
+func validateLabels(path string, labels []string) (errs []error) {
+	for i, label := range labels {
+		switch {
+		case !labelNamePattern.MatchString(label):
+			errs = append(errs, fmt.Errorf("%s[%d]: %q is not a valid label name", path, i, label))
+		case label == ProjectLabel:
+			errs = append(errs, fmt.Errorf("%s[%d]: %q is reserved for the name of the project", path, i, label))
+		case label == fetcher.PriceTypeLabel || label == fetcher.CurrencyLabel ||
+			slices.Contains(fetcher.LabelNames(), label):
+			errs = append(errs, fmt.Errorf("%s[%d]: %q is reserved by the exporter", path, i, label))
+		}
+	}
+	return errs
+}
+
+// ResolveToken returns the API token of the project from the configured source.
+func (project Project) ResolveToken() (string, error) {
+	switch {
+	case project.TokenEnv != "":
+		token, ok := os.LookupEnv(project.TokenEnv)
+		if !ok || token == "" {
+			return "", fmt.Errorf("project %s: environment variable %s is not set", project.Name, project.TokenEnv)
+		}
+		return token, nil
+
+	case project.TokenFile != "":
+		token, err := readTokenFile(project.TokenFile, project.AllowWorldReadableTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("project %s: %w", project.Name, err)
+		}
+		return token, nil
+
+	default:
+		return project.Token, nil
+	}
+}
+
+// readTokenFile reads a token from the passed file, without surrounding whitespace like trailing line breaks.
+func readTokenFile(path string, allowWorldReadable bool) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	if info.Mode().Perm()&0o004 != 0 && !allowWorldReadable {
+		return "", fmt.Errorf("token file %s is readable by every user, restrict its permissions or allow it", path)
+	}
+
+	raw, err := os.ReadFile(path) //nolint:gosec // reading the configured token file is intended
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// WatchedFiles returns the files that the configuration depends on, apart from the config file itself.
+func (config *Config) WatchedFiles() []string {
+	var files []string
+	for _, project := range config.Projects {
+		if project.TokenFile != "" {
+			files = append(files, project.TokenFile)
+		}
+	}
+	return files
+}
+
+// FetcherNames returns the names of the fetchers that run for the project.
+func (project Project) FetcherNames(config *Config) ([]string, error) {
+	if project.Fetchers != nil {
+		return project.Fetchers.Names()
+	}
+	return config.Fetchers.Names()
+}
+
+// StateDir returns the directory that keeps the persisted state of the project, or an empty string if no state is kept.
+func (project Project) StateDir(config *Config) string {
+	if config.StateDir == "" {
+		return ""
+	}
+	return filepath.Join(config.StateDir, project.Name)
+}
+
+// VATRateOf returns the VAT rate that replaces the one of the pricing information for the project, or nil if none does.
+func (project Project) VATRateOf(config *Config) *float64 {
+	if project.VATRate != nil {
+		return project.VATRate
+	}
+	return config.Pricing.VATRate
+}
+
+// Labels returns the additional labels that apply to the project.
+func (project Project) Labels(config *Config) []string {
+	if project.AdditionalLabels != nil {
+		return project.AdditionalLabels
+	}
+	return config.AdditionalLabels
+}
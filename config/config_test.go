@@ -0,0 +1,284 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jangraefen/hcloud-pricing-exporter/config"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeConfig(name, content string) string {
+	path := filepath.Join(GinkgoT().TempDir(), name)
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	return path
+}
+
+func loadConfig(content string) (*config.Config, error) {
+	cfg, err := config.Load(writeConfig("config.yaml", content))
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Finalize()
+}
+
+var _ = Describe("For the config file", func() {
+	When("it lists multiple projects", func() {
+		It("should load all of them", func() {
+			GinkgoT().Setenv("TEST_STAGING_TOKEN", "staging-token")
+
+			cfg, err := loadConfig(`
+projects:
+  - name: production
+    token: production-token
+  - name: staging
+    token_env: TEST_STAGING_TOKEN
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Projects).To(HaveLen(2))
+
+			token, err := cfg.Projects[1].ResolveToken()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("staging-token"))
+		})
+	})
+
+	When("a project reads its token from a file", func() {
+		It("should trim surrounding whitespace", func() {
+			tokenFile := writeConfig("token", "  file-token\n")
+			cfg, err := loadConfig(`
+projects:
+  - name: a
+    token_file: ` + tokenFile + `
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.WatchedFiles()).To(ConsistOf(tokenFile))
+
+			token, err := cfg.Projects[0].ResolveToken()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("file-token"))
+		})
+
+		It("should refuse the file, if every user can read it", func() {
+			tokenFile := writeConfig("token", "file-token")
+			Expect(os.Chmod(tokenFile, 0o644)).To(Succeed())
+
+			project := config.Project{Name: "a", TokenFile: tokenFile}
+			_, err := project.ResolveToken()
+			Expect(err).To(MatchError(ContainSubstring("is readable by every user")))
+
+			project.AllowWorldReadableTokenFile = true
+			Expect(project.ResolveToken()).To(Equal("file-token"))
+		})
+
+		It("should refuse an empty file", func() {
+			project := config.Project{Name: "a", TokenFile: writeConfig("token", " \n")}
+			_, err := project.ResolveToken()
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+	})
+
+	When("a project selects its own fetchers", func() {
+		It("should replace the global selection", func() {
+			cfg, err := loadConfig(`
+fetchers: {disabled: [server_traffic, loadbalancer_traffic]}
+projects:
+  - {name: a, token: x}
+  - {name: b, token: y, fetchers: {enabled: [server, volume]}}
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Projects[0].FetcherNames(cfg)).NotTo(ContainElement("server_traffic"))
+			Expect(cfg.Projects[1].FetcherNames(cfg)).To(Equal([]string{"server", "volume"}))
+		})
+	})
+
+	When("single fetchers run in their own interval", func() {
+		It("should keep them ready for three of their intervals", func() {
+			cfg, err := loadConfig(`
+fetch:
+  interval: 1m
+  intervals: {snapshot: 1h}
+projects: [{name: a, token: x}]
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Fetch.IntervalOf("snapshot")).To(Equal(time.Hour))
+			Expect(cfg.Fetch.IntervalOf("server")).To(Equal(time.Minute))
+			Expect(cfg.MaxAgeOf("snapshot")).To(Equal(3 * time.Hour))
+			Expect(cfg.MaxAgeOf("server")).To(Equal(3 * time.Minute))
+		})
+	})
+
+	When("it sets a state directory", func() {
+		It("should keep the state of each project in its own directory", func() {
+			cfg, err := loadConfig(`
+state_dir: /var/lib/hcloud-pricing-exporter
+projects:
+  - name: production
+    token: production-token
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Projects[0].StateDir(cfg)).To(Equal("/var/lib/hcloud-pricing-exporter/production"))
+
+			cfg.StateDir = ""
+			Expect(cfg.Projects[0].StateDir(cfg)).To(BeEmpty())
+		})
+	})
+
+	When("a project overrides the VAT rate", func() {
+		It("should replace the global one", func() {
+			cfg, err := loadConfig(`
+pricing: {vat_rate: 19}
+projects:
+  - {name: a, token: x}
+  - {name: reverse-charge, token: x, vat_rate: 0}
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Projects[0].VATRateOf(cfg)).To(HaveValue(Equal(19.0)))
+			Expect(cfg.Projects[1].VATRateOf(cfg)).To(HaveValue(BeZero()))
+
+			cfg.Pricing.VATRate = nil
+			Expect(cfg.Projects[0].VATRateOf(cfg)).To(BeNil())
+		})
+	})
+
+	When("it only sets some options", func() {
+		It("should keep the defaults for all others", func() {
+			cfg, err := loadConfig(`
+fetch:
+  interval: 5m
+projects:
+  - {name: a, token: x}
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Port).To(Equal(config.Default().Port))
+			Expect(cfg.Fetch.Interval).To(Equal(5 * time.Minute))
+			Expect(cfg.Fetch.Timeout).To(Equal(config.Default().Fetch.Timeout))
+			Expect(cfg.Fetch.CycleTimeout).To(Equal(5 * time.Minute))
+			Expect(cfg.Readiness.MaxAge).To(Equal(15 * time.Minute))
+			Expect(cfg.Pricing.RefreshInterval).To(Equal(50 * time.Minute))
+			Expect(cfg.RateLimit.OptionalFetchers).To(ConsistOf("snapshot"))
+			Expect(cfg.Pricing.PriceType).To(Equal(fetcher.PriceTypeGross))
+		})
+	})
+
+	When("it is written in JSON", func() {
+		It("should load it as well", func() {
+			cfg, err := config.Load(writeConfig("config.json", `{
+  "additional_labels": ["service"],
+  "fetch": {"interval": "2m", "concurrency": 0},
+  "projects": [{"name": "a", "token": "x", "additional_labels": ["owner"]}]
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Finalize()).To(Succeed())
+			Expect(cfg.Fetch.Interval).To(Equal(2 * time.Minute))
+			Expect(cfg.Fetch.Concurrency).To(BeZero())
+			Expect(cfg.Projects[0].Labels(cfg)).To(ConsistOf("owner"))
+		})
+	})
+
+	When("it is the documented example", func() {
+		It("should be valid", func() {
+			cfg, err := config.Load(filepath.Join("..", "config.example.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Finalize()).To(Succeed())
+		})
+	})
+
+	When("it is invalid", func() {
+		DescribeTable("should point to the offending value",
+			func(content, message string) {
+				_, err := loadConfig(content)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("without projects", "projects: []", "projects: at least one project is required"),
+			Entry("with duplicate names", `
+projects:
+  - {name: a, token: x}
+  - {name: a, token: y}
+`, `projects[1].name: "a" is used by more than one project`),
+			Entry("with an invalid name", `
+projects:
+  - {name: "a b", token: x}
+`, `projects[0].name: "a b" must only contain`),
+			Entry("without a token", `
+projects:
+  - {name: a}
+`, "projects[0]: exactly one of token, token_env and token_file is required"),
+			Entry("with unknown keys", `
+projects:
+  - {name: a, token: x, tokn: y}
+`, "field tokn not found"),
+			Entry("with a negative interval", `
+fetch: {interval: -1m}
+projects: [{name: a, token: x}]
+`, "fetch.interval: -1m0s must be positive"),
+			Entry("with an interval of an unknown fetcher", `
+fetch: {intervals: {snapshots: 1h}}
+projects: [{name: a, token: x}]
+`, `fetch.intervals.snapshots: unknown fetcher "snapshots"`),
+			Entry("with an empty interval of a fetcher", `
+fetch: {intervals: {snapshot: 0s}}
+projects: [{name: a, token: x}]
+`, "fetch.intervals.snapshot: 0s must be positive"),
+			Entry("with a malformed duration", `
+fetch: {timeout: soon}
+projects: [{name: a, token: x}]
+`, "cannot unmarshal !!str `soon` into time.Duration"),
+			Entry("with an unknown price type", `
+pricing: {price_type: vat}
+projects: [{name: a, token: x}]
+`, `pricing.price_type: unknown price type "vat"`),
+			Entry("with an invalid currency", `
+pricing: {currency: usd, rates_file: rates.xml}
+projects: [{name: a, token: x}]
+`, `pricing.currency: "usd" is not a currency code`),
+			Entry("with a currency but no rates", `
+pricing: {currency: CHF}
+projects: [{name: a, token: x}]
+`, "pricing.currency: converting prices to CHF requires pricing.rates_file"),
+			Entry("with the reserved currency label", `
+additional_labels: [currency]
+projects: [{name: a, token: x}]
+`, `additional_labels[0]: "currency" is reserved by the exporter`),
+			Entry("with a label of the fetchers", `
+projects: [{name: a, token: x, additional_labels: [team, location]}]
+`, `projects[0].additional_labels[1]: "location" is reserved by the exporter`),
+			Entry("with a negative VAT rate of a project", `
+projects: [{name: a, token: x, vat_rate: -5}]
+`, "projects[0].vat_rate: -5 must be between 0 and 100"),
+			Entry("with an adjustment of an unknown resource", `
+pricing: {adjustments: [{resource: servers, percent: -10}]}
+projects: [{name: a, token: x}]
+`, `pricing.adjustments[0].resource: unknown fetcher "servers"`),
+			Entry("with an adjustment without effect", `
+pricing: {adjustments: [{resource: server, labels: {team: a}}]}
+projects: [{name: a, token: x}]
+`, "pricing.adjustments[0]: one of percent and amount is required"),
+			Entry("with an invalid port", `
+port: 70000
+projects: [{name: a, token: x}]
+`, "port: 70000 is not a valid port"),
+			Entry("with the reserved project label", `
+additional_labels: [service, project]
+projects: [{name: a, token: x}]
+`, `additional_labels[1]: "project" is reserved`),
+			Entry("with an unknown fetcher", `
+fetchers: {disabled: [floatingips]}
+projects: [{name: a, token: x}]
+`, `fetchers.disabled[0]: unknown fetcher "floatingips"`),
+			Entry("with an unknown optional fetcher", `
+rate_limit: {optional_fetchers: [snapshots]}
+projects: [{name: a, token: x}]
+`, `rate_limit.optional_fetchers[0]: unknown fetcher "snapshots"`),
+			Entry("without any fetcher for a project", `
+projects: [{name: a, token: x, fetchers: {enabled: [volume], disabled: [volume]}}]
+`, "projects[0].fetchers: at least one fetcher must be enabled"),
+			Entry("with an invalid project label", `
+projects: [{name: a, token: x, additional_labels: [team-name]}]
+`, `projects[0].additional_labels[0]: "team-name" is not a valid label name`),
+		)
+	})
+})
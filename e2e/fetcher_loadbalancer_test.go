@@ -7,7 +7,6 @@ import (
 	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var _ = Describe("For loadbalancers", Ordered, Label("loadbalancers"), func() {
@@ -34,20 +33,20 @@ var _ = Describe("For loadbalancers", Ordered, Label("loadbalancers"), func() {
 
 	//nolint:dupl
 	When("getting prices", func() {
-		It("should fetch them", func() {
-			Expect(sut.Run(testClient)).To(Succeed())
+		It("should fetch them", func(ctx context.Context) {
+			Expect(sut.Run(ctx, fetcher.NewInventory(testClient))).To(Succeed())
 		})
 
 		It("should get prices for correct values", func() {
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-loadbalancer", "fsn1", "lb11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
-			Expect(testutil.ToFloat64(sut.GetMonthly().WithLabelValues("test-loadbalancer", "fsn1", "lb11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
+			Expect(hourlyCost(sut, "test-loadbalancer", "fsn1", "lb11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
+			Expect(monthlyCost(sut, "test-loadbalancer", "fsn1", "lb11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
 		})
 
 		It("should get zero for incorrect values", func() {
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("invalid-name", "fsn1", "lb11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-loadbalancer", "nbg1", "lb11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-loadbalancer", "fsn1", "lb21", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-loadbalancer", "fsn1", "lb11", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "invalid-name", "fsn1", "lb11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-loadbalancer", "nbg1", "lb11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-loadbalancer", "fsn1", "lb21", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-loadbalancer", "fsn1", "lb11", "e3e_suite_test")).Should(BeNumerically("==", 0))
 		})
 	})
 })
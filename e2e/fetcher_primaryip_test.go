@@ -7,7 +7,6 @@ import (
 	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var _ = Describe("For primary IPs", Ordered, Label("primaryips"), func() {
@@ -43,35 +42,35 @@ var _ = Describe("For primary IPs", Ordered, Label("primaryips"), func() {
 
 	//nolint:dupl
 	When("getting prices", func() {
-		It("should fetch them", func() {
+		It("should fetch them", func(ctx context.Context) {
 			By("Running the price collection")
-			Expect(sut.Run(testClient)).To(Succeed())
+			Expect(sut.Run(ctx, fetcher.NewInventory(testClient))).To(Succeed())
 		})
 
 		It("should get prices for correct values for v4", func() {
 			By("Checking IPv4 prices")
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-primaryipv4", "fsn1-dc14", "ipv4", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
-			Expect(testutil.ToFloat64(sut.GetMonthly().WithLabelValues("test-primaryipv4", "fsn1-dc14", "ipv4", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
+			Expect(hourlyCost(sut, "test-primaryipv4", "fsn1-dc14", "ipv4", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
+			Expect(monthlyCost(sut, "test-primaryipv4", "fsn1-dc14", "ipv4", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
 		})
 
 		It("should get prices for correct values for v6", func() {
 			By("Checking IPv6 prices")
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-primaryipv6", "fsn1-dc14", "ipv6", "e2e_suite_test"))).Should(BeNumerically("==", 0.0))
-			Expect(testutil.ToFloat64(sut.GetMonthly().WithLabelValues("test-primaryipv6", "fsn1-dc14", "ipv6", "e2e_suite_test"))).Should(BeNumerically("==", 0.0))
+			Expect(hourlyCost(sut, "test-primaryipv6", "fsn1-dc14", "ipv6", "e2e_suite_test")).Should(BeNumerically("==", 0.0))
+			Expect(monthlyCost(sut, "test-primaryipv6", "fsn1-dc14", "ipv6", "e2e_suite_test")).Should(BeNumerically("==", 0.0))
 		})
 
 		It("should get zero for incorrect values", func() {
 			By("Checking IPv4 prices")
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("invalid-name", "fsn1-dc14", "ipv4", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv4", "nbg1-dc14", "ipv4", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv4", "fsn1-dc14", "ipv6", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv4", "fsn1-dc14", "ipv4", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "invalid-name", "fsn1-dc14", "ipv4", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv4", "nbg1-dc14", "ipv4", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv4", "fsn1-dc14", "ipv6", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv4", "fsn1-dc14", "ipv4", "e3e_suite_test")).Should(BeNumerically("==", 0))
 
 			By("Checking IPv6 prices")
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("invalid-name", "fsn1-dc14", "ipv6", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv6", "nbg1-dc14", "ipv6", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv6", "fsn1-dc14", "ipv4", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("est-primaryipv6", "fsn1-dc14", "ipv6", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "invalid-name", "fsn1-dc14", "ipv6", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv6", "nbg1-dc14", "ipv6", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv6", "fsn1-dc14", "ipv4", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "est-primaryipv6", "fsn1-dc14", "ipv6", "e3e_suite_test")).Should(BeNumerically("==", 0))
 		})
 	})
 })
@@ -8,7 +8,6 @@ import (
 	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var _ = Describe("For servers", Ordered, Label("servers"), func() {
@@ -69,34 +68,34 @@ var _ = Describe("For servers", Ordered, Label("servers"), func() {
 
 	//nolint:dupl
 	When("getting prices", func() {
-		It("should fetch them", func() {
+		It("should fetch them", func(ctx context.Context) {
 			By("Running the price collection")
-			Expect(sutServer.Run(testClient)).To(Succeed())
-			Expect(sutBackup.Run(testClient)).To(Succeed())
+			Expect(sutServer.Run(ctx, fetcher.NewInventory(testClient))).To(Succeed())
+			Expect(sutBackup.Run(ctx, fetcher.NewInventory(testClient))).To(Succeed())
 		})
 
 		It("should get prices for correct values", func() {
 			By("Checking server prices")
-			Expect(testutil.ToFloat64(sutServer.GetHourly().WithLabelValues("test-server", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
-			Expect(testutil.ToFloat64(sutServer.GetMonthly().WithLabelValues("test-server", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
+			Expect(hourlyCost(sutServer, "test-server", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
+			Expect(monthlyCost(sutServer, "test-server", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
 
 			By("Checking server backup prices")
-			Expect(testutil.ToFloat64(sutBackup.GetHourly().WithLabelValues("test-server", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
-			Expect(testutil.ToFloat64(sutBackup.GetMonthly().WithLabelValues("test-server", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
+			Expect(hourlyCost(sutBackup, "test-server", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
+			Expect(monthlyCost(sutBackup, "test-server", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
 		})
 
 		It("should get zero for incorrect values", func() {
 			By("Checking server prices")
-			Expect(testutil.ToFloat64(sutServer.GetHourly().WithLabelValues("invalid-name", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutServer.GetHourly().WithLabelValues("test-server", "nbg1", "cx11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutServer.GetHourly().WithLabelValues("test-server", "fsn1", "cx21", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutServer.GetHourly().WithLabelValues("test-server", "fsn1", "cx11", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutServer, "invalid-name", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutServer, "test-server", "nbg1", "cx11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutServer, "test-server", "fsn1", "cx21", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutServer, "test-server", "fsn1", "cx11", "e3e_suite_test")).Should(BeNumerically("==", 0))
 
 			By("Checking server backup prices")
-			Expect(testutil.ToFloat64(sutBackup.GetHourly().WithLabelValues("invalid-name", "fsn1", "cx11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutBackup.GetHourly().WithLabelValues("test-server", "nbg1", "cx11", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutBackup.GetHourly().WithLabelValues("test-server", "fsn1", "cx21", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sutBackup.GetHourly().WithLabelValues("test-server", "fsn1", "cx11", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutBackup, "invalid-name", "fsn1", "cx11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutBackup, "test-server", "nbg1", "cx11", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutBackup, "test-server", "fsn1", "cx21", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sutBackup, "test-server", "fsn1", "cx11", "e3e_suite_test")).Should(BeNumerically("==", 0))
 		})
 	})
 })
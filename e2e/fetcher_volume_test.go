@@ -7,7 +7,6 @@ import (
 	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var _ = Describe("For volumes", Ordered, Label("volumes"), func() {
@@ -31,20 +30,20 @@ var _ = Describe("For volumes", Ordered, Label("volumes"), func() {
 
 	//nolint:dupl
 	When("getting prices", func() {
-		It("should fetch them", func() {
-			Expect(sut.Run(testClient)).To(Succeed())
+		It("should fetch them", func(ctx context.Context) {
+			Expect(sut.Run(ctx, fetcher.NewInventory(testClient))).To(Succeed())
 		})
 
 		It("should get prices for correct values", func() {
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-volume", "fsn1", "10", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
-			Expect(testutil.ToFloat64(sut.GetMonthly().WithLabelValues("test-volume", "fsn1", "10", "e2e_suite_test"))).Should(BeNumerically(">", 0.0))
+			Expect(hourlyCost(sut, "test-volume", "fsn1", "10", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
+			Expect(monthlyCost(sut, "test-volume", "fsn1", "10", "e2e_suite_test")).Should(BeNumerically(">", 0.0))
 		})
 
 		It("should get zero for incorrect values", func() {
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("invalid-name", "fsn1", "10", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-volume", "nbg1", "10", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-volume", "fsn1", "99", "e2e_suite_test"))).Should(BeNumerically("==", 0))
-			Expect(testutil.ToFloat64(sut.GetHourly().WithLabelValues("test-volume", "fsn1", "10", "e3e_suite_test"))).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "invalid-name", "fsn1", "10", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-volume", "nbg1", "10", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-volume", "fsn1", "99", "e2e_suite_test")).Should(BeNumerically("==", 0))
+			Expect(hourlyCost(sut, "test-volume", "fsn1", "10", "e3e_suite_test")).Should(BeNumerically("==", 0))
 		})
 	})
 })
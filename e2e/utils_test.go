@@ -12,6 +12,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
 )
 
 func hcloudAPITokenFromENV() string {
@@ -39,6 +40,22 @@ func waitUntilActionSucceeds(ctx context.Context, actionToTrack *hcloud.Action)
 	}
 }
 
+func hourlyCost(sut fetcher.Fetcher, labels ...string) float64 {
+	if resource := sut.Snapshot().Find(labels...); resource != nil {
+		return resource.Hourly.Gross
+	}
+
+	return 0
+}
+
+func monthlyCost(sut fetcher.Fetcher, labels ...string) float64 {
+	if resource := sut.Snapshot().Find(labels...); resource != nil {
+		return resource.Monthly.Gross
+	}
+
+	return 0
+}
+
 func generatePublicKey() string {
 	public, _, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccruedCost is the cost that a resource accrued in the current month so far.
+type AccruedCost struct {
+	// ID identifies the resource among the ones of its type, even if its labels change. Zero if unknown.
+	ID int `json:"id,omitempty"`
+	// Labels contains the last known label values of the resource, in the order of the label names of its fetcher.
+	Labels []string `json:"labels"`
+	// Since is the point in time from which the resource is billed in the current month.
+	Since time.Time `json:"since"`
+	// Hours is the number of started hours that the cost was accrued for.
+	Hours float64 `json:"hours"`
+	Cost  Price   `json:"cost"`
+	// Forecast is the cost that the resource is expected to accrue until the end of the month, at its current hourly
+	// price. Resources that disappeared are expected to accrue nothing more.
+	Forecast Price `json:"forecast"`
+}
+
+// accrual tracks the costs that the resources of a fetcher accrued in the current month. HCloud bills every started
+// hour of a resource, but never more than its monthly price. Resources are tracked by their ID, so that resizing,
+// rescaling or relabelling them continues their costs at the new price. Resources that disappear keep their accrued
+// costs until the month is over.
+type accrual struct {
+	lock      sync.Mutex
+	month     time.Time
+	resources map[string]AccruedCost
+}
+
+type accrualState struct {
+	Month time.Time `json:"month"`
+	// Labels are the label names of the fetcher, in the order of the label values of the resources.
+	Labels    []string      `json:"labels"`
+	Resources []AccruedCost `json:"resources"`
+}
+
+func newAccrual() *accrual {
+	return &accrual{resources: map[string]AccruedCost{}}
+}
+
+// update accrues the costs of the priced resources of the snapshot up to the passed point in time and returns the
+// accrued costs of all resources of the month, including the ones that are not part of the snapshot.
+func (accrual *accrual) update(snapshot *Snapshot, now time.Time) []AccruedCost {
+	accrual.lock.Lock()
+	defer accrual.lock.Unlock()
+
+	accrual.startMonth(now)
+	for key, cost := range accrual.resources {
+		cost.Forecast = cost.Cost
+		accrual.resources[key] = cost
+	}
+
+	// The started hour is already accrued, so only the full hours until the end of the month remain.
+	monthEnd := accrual.month.AddDate(0, 0, daysInMonth(accrual.month))
+	remainingHours := math.Floor(monthEnd.Sub(now).Hours())
+	for _, resource := range snapshot.Resources {
+		key := accrualKey(resource.ID, resource.Labels)
+		previous, known := accrual.resources[key]
+
+		// Resources without a creation date are billed from the point in time they were first seen.
+		since := previous.Since
+		if !known {
+			since = resource.Created
+			if since.IsZero() || since.After(now) {
+				since = now
+			}
+			if since.Before(accrual.month) {
+				since = accrual.month
+			}
+		}
+
+		// Only the hours that started since the last update are billed at the current price. The monthly price caps
+		// the costs, unless more was accrued at a higher price before.
+		hours := math.Max(1, math.Ceil(now.Sub(since).Hours()))
+		limit := resource.Monthly.atLeast(previous.Cost)
+		cost := previous.Cost.plus(resource.Hourly.times(math.Max(0, hours-previous.Hours))).atMost(limit)
+		accrual.resources[key] = AccruedCost{
+			ID:       resource.ID,
+			Labels:   resource.Labels,
+			Since:    since,
+			Hours:    hours,
+			Cost:     cost,
+			Forecast: cost.plus(resource.Hourly.times(remainingHours)).atMost(limit),
+		}
+	}
+
+	return accrual.costs()
+}
+
+// startMonth forgets all accrued costs, if the passed point in time belongs to a later month than the tracked one.
+func (accrual *accrual) startMonth(now time.Time) {
+	now = now.UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month.After(accrual.month) {
+		accrual.month = month
+		accrual.resources = map[string]AccruedCost{}
+	}
+}
+
+// accrualKey identifies a resource by its ID, or by its labels if the ID is unknown.
+func accrualKey(id int, labels []string) string {
+	if id != 0 {
+		return strconv.Itoa(id)
+	}
+	return "labels\x00" + labelKey(labels)
+}
+
+// costs returns the accrued costs of all tracked resources, ordered by their labels and IDs.
+func (accrual *accrual) costs() []AccruedCost {
+	costs := make([]AccruedCost, 0, len(accrual.resources))
+	for _, cost := range accrual.resources {
+		costs = append(costs, cost)
+	}
+	slices.SortFunc(costs, func(a, b AccruedCost) int {
+		if order := slices.Compare(a.Labels, b.Labels); order != 0 {
+			return order
+		}
+		return a.ID - b.ID
+	})
+	return costs
+}
+
+func (accrual *accrual) save(store *StateStore, name string, labels []string) error {
+	accrual.lock.Lock()
+	state := accrualState{Month: accrual.month, Labels: labels, Resources: accrual.costs()}
+	accrual.lock.Unlock()
+
+	if len(state.Resources) == 0 {
+		return nil
+	}
+	return saveState(store, "accrued_"+name, state)
+}
+
+// restore takes over the persisted costs, unless costs of the same or a later month are tracked already. Costs that
+// were persisted with other label names are dropped, as their label values cannot be exposed anymore.
+func (accrual *accrual) restore(store *StateStore, name string, labels []string) error {
+	state, _, ok, err := loadState[accrualState](store, "accrued_"+name)
+	if err != nil || !ok {
+		return err
+	}
+	if !slices.Equal(state.Labels, labels) {
+		log.Printf("Dropping the persisted accrued costs of %s, because its labels changed", name)
+		return nil
+	}
+
+	accrual.lock.Lock()
+	defer accrual.lock.Unlock()
+
+	if !state.Month.After(accrual.month) {
+		return nil
+	}
+	accrual.month = state.Month
+	accrual.resources = make(map[string]AccruedCost, len(state.Resources))
+	for _, cost := range state.Resources {
+		accrual.resources[accrualKey(cost.ID, cost.Labels)] = cost
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package fetcher_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For accrued costs", func() {
+	var (
+		api     *fakeAPI
+		client  *hcloud.Client
+		clock   *fakeClock
+		pricing *fetcher.PriceProvider
+		server  fetcher.Fetcher
+	)
+
+	BeforeEach(func() {
+		api, client = newFakeAPI(map[string]int{"servers": 2})
+		// The fake resources were created on 2024-01-01 at midnight.
+		clock = &fakeClock{now: time.Date(2024, time.January, 2, 10, 30, 0, 0, time.UTC)}
+		pricing = &fetcher.PriceProvider{Client: client}
+		fetcher.SetClock(pricing, clock.Now)
+		server = fetcher.NewServer(pricing)
+	})
+
+	accrued := func(fetcher fetcher.Fetcher, name string) float64 {
+		for _, cost := range fetcher.Snapshot().Accrued {
+			if cost.Labels[0] == name {
+				return cost.Cost.Gross
+			}
+		}
+		Fail("no accrued costs of " + name)
+		return 0
+	}
+
+	It("should bill every started hour since the creation", func(ctx context.Context) {
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 35*0.00714, 1e-5))
+		Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_month_to_date")).To(Equal(2))
+	})
+
+	It("should cap the costs at the monthly price", func(ctx context.Context) {
+		clock.Advance(25 * 24 * time.Hour)
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 4.5101, 1e-5))
+	})
+
+	It("should keep the costs of deleted resources until the month is over", func(ctx context.Context) {
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		api.SetResources("servers", 1)
+		clock.Advance(time.Hour)
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 36*0.00714, 1e-5))
+		Expect(accrued(server, "servers-2")).To(BeNumerically("~", 35*0.00714, 1e-5))
+
+		clock.Advance(30 * 24 * time.Hour)
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(server.Snapshot().Accrued).To(HaveLen(1))
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 12*0.00714, 1e-5))
+	})
+
+	It("should continue the costs of resized resources at the new price", func(ctx context.Context) {
+		api.SetResources("volumes", 1)
+		volume := fetcher.NewVolume(pricing)
+		Expect(fetcher.Fetchers{volume}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		smallHourly := volume.Snapshot().Find("volumes-1", "fsn1", "10").Hourly.Gross
+
+		api.Change("volumes", "size", 20)
+		clock.Advance(time.Hour)
+		Expect(fetcher.Fetchers{volume}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		largeHourly := volume.Snapshot().Find("volumes-1", "fsn1", "20").Hourly.Gross
+
+		Expect(volume.Snapshot().Accrued).To(HaveLen(1))
+		Expect(volume.Snapshot().Accrued[0].Labels).To(Equal([]string{"volumes-1", "fsn1", "20"}))
+		Expect(accrued(volume, "volumes-1")).To(BeNumerically("~", 35*smallHourly+largeHourly, 1e-6))
+	})
+
+	It("should continue the costs of rescaled servers", func(ctx context.Context) {
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		api.Change("servers", "server_type", map[string]interface{}{"name": "cx32", "prices": []interface{}{
+			map[string]interface{}{
+				"location":      "fsn1",
+				"price_hourly":  map[string]string{"net": "0.01", "gross": "0.0119"},
+				"price_monthly": map[string]string{"net": "6.8", "gross": "8.092"},
+			},
+		}})
+		clock.Advance(time.Hour)
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(server.Snapshot().Accrued).To(HaveLen(2))
+		Expect(server.Snapshot().Accrued[0].Labels).To(Equal([]string{"servers-1", "fsn1", "cx32"}))
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 35*0.00714+0.0119, 1e-5))
+	})
+
+	It("should expose resources that took over the labels of a deleted one as a single series",
+		func(ctx context.Context) {
+			Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			api.SetResources("servers", 1)
+			api.Change("servers", "name", "servers-2")
+			clock.Advance(time.Hour)
+			Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			Expect(server.Snapshot().Accrued).To(HaveLen(2))
+			Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_month_to_date")).To(Equal(1))
+			Expect(monthToDate(server)).To(BeNumerically("~", (36+35)*0.00714, 1e-5))
+		})
+
+	It("should forecast the costs until the end of the month per type and in total", func(ctx context.Context) {
+		api.SetResources("volumes", 1)
+		api.SetCreated(time.Date(2024, time.January, 25, 0, 0, 0, 0, time.UTC))
+		clock.Advance(28 * 24 * time.Hour)
+		volume := fetcher.NewVolume(pricing)
+		fetchers := fetcher.Fetchers{server, volume}
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		// Both servers accrued 131 started hours until 2024-01-30 10:30 and run for 37 more full hours.
+		Expect(accrued(server, "servers-1")).To(BeNumerically("~", 131*0.00714, 1e-5))
+		Expect(forecasts(fetchers)).To(HaveKeyWithValue("server", BeNumerically("~", 2*168*0.00714, 1e-4)))
+
+		// A deleted server is expected to accrue nothing more.
+		api.SetResources("servers", 1)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(forecasts(fetchers)).To(HaveKeyWithValue("server", BeNumerically("~", (168+131)*0.00714, 1e-4)))
+
+		costs := forecasts(fetchers)
+		Expect(costs["volume"]).To(BeNumerically(">", 0))
+		Expect(costs[""]).To(BeNumerically("~", costs["server"]+costs["volume"], 1e-9))
+	})
+
+	It("should clamp the forecast to the monthly price", func(ctx context.Context) {
+		fetchers := fetcher.Fetchers{server}
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(forecasts(fetchers)).To(HaveKeyWithValue("", BeNumerically("~", 2*4.5101, 1e-4)))
+	})
+
+	It("should keep the forecast of resized resources within the monthly price", func(ctx context.Context) {
+		api.SetResources("volumes", 1)
+		volume := fetcher.NewVolume(pricing)
+		fetchers := fetcher.Fetchers{volume}
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		api.Change("volumes", "size", 20)
+		clock.Advance(time.Hour)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		monthly := volume.Snapshot().Find("volumes-1", "fsn1", "20").Monthly.Gross
+		Expect(forecasts(fetchers)).To(HaveKeyWithValue("volume", BeNumerically("<=", monthly+1e-9)))
+		Expect(forecasts(fetchers)).To(HaveKeyWithValue("", BeNumerically("<=", monthly+1e-9)))
+	})
+
+	It("should keep the costs across restarts", func(ctx context.Context) {
+		state, err := fetcher.NewStateStore(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+		api.SetResources("servers", 1)
+		restarted := fetcher.Fetchers{fetcher.NewServer(pricing)}
+		Expect(restarted.RestoreAccrued(state)).To(Succeed())
+		Expect(restarted.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+		Expect(restarted[0].Snapshot().Accrued).To(HaveLen(2))
+		Expect(accrued(restarted[0], "servers-2")).To(BeNumerically("~", 35*0.00714, 1e-5))
+	})
+
+	It("should drop the costs persisted with other labels", func(ctx context.Context) {
+		state, err := fetcher.NewStateStore(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		labelled := fetcher.NewServer(pricing, "team")
+		Expect(fetcher.Fetchers{labelled}.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+		api.SetResources("servers", 1)
+		restarted := fetcher.Fetchers{fetcher.NewServer(pricing)}
+		Expect(restarted.RestoreAccrued(state)).To(Succeed())
+		Expect(restarted.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+		Expect(restarted[0].Snapshot().Accrued).To(HaveLen(1))
+		Expect(testutil.CollectAndCount(restarted[0], "hcloud_pricing_server_month_to_date")).To(Equal(1))
+	})
+})
+
+// forecasts gathers the forecasts of the fetchers by resource type, the total is keyed by an empty resource type.
+func forecasts(fetchers fetcher.Fetchers) map[string]float64 {
+	registry := prometheus.NewPedanticRegistry()
+	Expect(fetchers.RegisterCollectors(registry)).To(Succeed())
+	families, err := registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	costs := map[string]float64{}
+	for _, family := range families {
+		switch family.GetName() {
+		case "hcloud_pricing_forecast_month":
+			for _, metric := range family.GetMetric() {
+				costs[metric.GetLabel()[1].GetValue()] = metric.GetGauge().GetValue()
+			}
+		case "hcloud_pricing_forecast_month_total":
+			costs[""] = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return costs
+}
+
+// monthToDate gathers the accrued costs of the first series of the server fetcher.
+func monthToDate(server fetcher.Fetcher) float64 {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(server)
+	families, err := registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() == "hcloud_pricing_server_month_to_date" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	Fail("no accrued costs exposed")
+	return 0
+}
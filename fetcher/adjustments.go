@@ -0,0 +1,64 @@
+package fetcher
+
+import "slices"
+
+// Adjustment changes the prices of the resources that it matches, e.g. to account for a negotiated discount or for a
+// markup that is charged back to internal teams. Empty criteria match every resource.
+type Adjustment struct {
+	// Resource is the name of the fetcher whose resources are matched, e.g. server or volume.
+	Resource string
+	// ServerType is the name of the server type of the matched servers, their backups and their traffic.
+	ServerType string
+	// Location is the name of the location of the matched resources.
+	Location string
+	// Labels must all be set to the same values on the matched resources.
+	Labels map[string]string
+	// Percent changes the prices relatively, e.g. -10 for a discount of ten percent.
+	Percent float64
+	// Amount changes the monthly net price absolutely in the exposed currency, e.g. -5 for a credit. It is applied after
+	// Percent, the hourly price changes by its share of the current month and the gross price by the VAT on top. Costs
+	// that derive from another resource, like the backups and the traffic of a server, only change by an amount if
+	// Resource names them, so that a resource is not charged more than once.
+	Amount float64
+}
+
+// derivedResources are the fetchers whose costs derive from the resources of another fetcher.
+var derivedResources = []string{"server_backup", "server_traffic", "loadbalancer_traffic"}
+
+func (adjustment Adjustment) matches(resource string, info resourceInfo) bool {
+	if adjustment.Resource != "" && adjustment.Resource != resource ||
+		adjustment.ServerType != "" && adjustment.ServerType != info.serverType ||
+		adjustment.Location != "" && adjustment.Location != info.location {
+		return false
+	}
+
+	for key, value := range adjustment.Labels {
+		if actual, ok := info.labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// adjust applies all adjustments that match the resource in the order they are listed. Prices never drop below zero
+// and resources without costs, like servers without backups, stay without costs.
+func (provider *PriceProvider) adjust(resource string, info resourceInfo, hourly, monthly Price) (Price, Price) {
+	if provider == nil || hourly == (Price{}) && monthly == (Price{}) {
+		return hourly, monthly
+	}
+
+	rate, _ := provider.vatRate()
+	for _, adjustment := range provider.Adjustments {
+		if !adjustment.matches(resource, info) {
+			continue
+		}
+
+		amount := Price{Net: adjustment.Amount, Gross: adjustment.Amount * (1 + rate/100)}
+		if adjustment.Resource == "" && slices.Contains(derivedResources, resource) {
+			amount = Price{}
+		}
+		hourly = hourly.times(1 + adjustment.Percent/100).plus(pricingPerHour(amount)).atLeastZero()
+		monthly = monthly.times(1 + adjustment.Percent/100).plus(amount).atLeastZero()
+	}
+	return hourly, monthly
+}
@@ -0,0 +1,81 @@
+package fetcher_test
+
+import (
+	"context"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For price adjustments", func() {
+	var client *hcloud.Client
+
+	BeforeEach(func() {
+		_, client = newFakeAPI(map[string]int{"servers": 1, "volumes": 1})
+	})
+
+	run := func(ctx context.Context, adjustments ...fetcher.Adjustment) (server, volume fetcher.Fetcher) {
+		pricing := &fetcher.PriceProvider{Client: client, Adjustments: adjustments}
+		server, volume = fetcher.NewServer(pricing), fetcher.NewVolume(pricing)
+		Expect(fetcher.Fetchers{server, volume}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		return server, volume
+	}
+
+	It("should apply percentages to the matching resources only", func(ctx context.Context) {
+		server, volume := run(ctx, fetcher.Adjustment{Resource: "server", ServerType: "cx22", Location: "fsn1", Percent: -10})
+
+		costs := server.Snapshot().Find("servers-1", "fsn1", "cx22")
+		Expect(costs.Monthly.Gross).To(BeNumerically("~", 4.5101*0.9, 1e-5))
+		Expect(costs.ListMonthly.Gross).To(BeNumerically("~", 4.5101, 1e-5))
+		Expect(costs.Hourly.Net).To(BeNumerically("~", 0.006*0.9, 1e-6))
+
+		costs = volume.Snapshot().Find("volumes-1", "fsn1", "10")
+		Expect(costs.Monthly).To(Equal(costs.ListMonthly))
+	})
+
+	It("should add absolute amounts with VAT to resources with matching labels", func(ctx context.Context) {
+		_, volume := run(ctx,
+			fetcher.Adjustment{Labels: map[string]string{"team": "fake"}, Amount: 1},
+			fetcher.Adjustment{Labels: map[string]string{"team": "other"}, Amount: 100},
+		)
+
+		monthly := volume.Snapshot().Find("volumes-1", "fsn1", "10").Monthly
+		Expect(monthly.Net).To(BeNumerically("~", 0.44+1, 1e-5))
+		Expect(monthly.Gross).To(BeNumerically("~", 0.5236+1.19, 1e-5))
+	})
+
+	It("should charge absolute amounts once per server, not again for its backups", func(ctx context.Context) {
+		pricing := &fetcher.PriceProvider{Client: client, Adjustments: []fetcher.Adjustment{
+			{Labels: map[string]string{"team": "fake"}, Amount: 2.5, Percent: -10},
+			{Resource: "server_backup", Amount: 1},
+		}}
+		server, backup := fetcher.NewServer(pricing), fetcher.NewServerBackup(pricing)
+		Expect(fetcher.Fetchers{server, backup}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(server.Snapshot().Find("servers-1", "fsn1", "cx22").Monthly.Net).
+			To(BeNumerically("~", 3.79*0.9+2.5, 1e-5))
+		costs := backup.Snapshot().Find("servers-1", "fsn1", "cx22")
+		Expect(costs.ListMonthly.Net).To(BeNumerically(">", 0))
+		Expect(costs.Monthly.Net).To(BeNumerically("~", costs.ListMonthly.Net*0.9+1, 1e-5))
+	})
+
+	It("should never adjust prices below zero", func(ctx context.Context) {
+		server, _ := run(ctx, fetcher.Adjustment{Resource: "server", Amount: -100})
+
+		costs := server.Snapshot().Find("servers-1", "fsn1", "cx22")
+		Expect(costs.Monthly).To(Equal(fetcher.Price{}))
+		Expect(costs.Hourly).To(Equal(fetcher.Price{}))
+	})
+
+	It("should expose the list prices only, if prices are adjusted", func(ctx context.Context) {
+		server, _ := run(ctx)
+		Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_list_monthly")).To(BeZero())
+
+		server, _ = run(ctx, fetcher.Adjustment{Resource: "volume", Percent: 20})
+		Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_list_hourly")).To(Equal(1))
+		Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_list_monthly")).To(Equal(1))
+	})
+})
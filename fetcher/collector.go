@@ -0,0 +1,243 @@
+package fetcher
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PricedResource holds the costs of a single HCloud resource.
+type PricedResource struct {
+	// Labels contains the label values of the resource, in the order of the label names of its fetcher.
+	Labels []string
+	// Hourly and Monthly are the costs with all matching adjustments applied.
+	Hourly  Price
+	Monthly Price
+	// ListHourly and ListMonthly are the list prices of the resource, before any adjustments.
+	ListHourly  Price
+	ListMonthly Price
+	// ID identifies the resource among the ones of its type, even if its labels change. Zero if unknown.
+	ID int
+	// Created is the point in time the resource was created, if known.
+	Created time.Time
+}
+
+// resourceInfo describes a priced resource by the attributes that adjustments match and that its costs accrue from.
+type resourceInfo struct {
+	// id identifies the resource among the ones of its type, even if its labels change.
+	id         int
+	serverType string
+	location   string
+	labels     map[string]string
+	// created is the point in time the resource was created, if known.
+	created time.Time
+}
+
+// Snapshot is an immutable set of priced resources, as collected by a single data fetching cycle. A snapshot must not
+// be modified after it has been published.
+type Snapshot struct {
+	// Timestamp is the point in time at which the data of the snapshot was collected.
+	Timestamp time.Time
+	// Stale is set, if the data fetching cycles after this snapshot failed.
+	Stale bool
+	// Currency is the currency of all costs of the snapshot.
+	Currency  string
+	Resources []PricedResource
+	// Accrued contains the costs of the current month so far, including the ones of resources that disappeared since.
+	Accrued []AccruedCost
+
+	index map[string]int
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		Timestamp: time.Now(),
+		index:     map[string]int{},
+	}
+}
+
+// Find returns the priced resource with exactly the passed label values or nil, if no such resource is known.
+func (snapshot *Snapshot) Find(labels ...string) *PricedResource {
+	if i, ok := snapshot.index[labelKey(labels)]; ok {
+		return &snapshot.Resources[i]
+	}
+
+	return nil
+}
+
+// add records the costs of a resource. Resources with identical label values are merged, the last one wins.
+func (snapshot *Snapshot) add(resource PricedResource) {
+	key := labelKey(resource.Labels)
+	if i, ok := snapshot.index[key]; ok {
+		snapshot.Resources[i] = resource
+		return
+	}
+
+	snapshot.index[key] = len(snapshot.Resources)
+	snapshot.Resources = append(snapshot.Resources, resource)
+}
+
+func labelKey(labels []string) string {
+	return strings.Join(labels, "\x00")
+}
+
+// metricFamily defines a metric family that is exposed for every priced resource of a fetcher. New families only need
+// to be added to pricedResourceFamilies to be exposed by all fetchers.
+type metricFamily struct {
+	suffix string
+	help   string
+	value  func(PricedResource) Price
+}
+
+var pricedResourceFamilies = []metricFamily{
+	{
+		suffix: "hourly",
+		help:   "The cost of the resource %s per hour",
+		value:  func(resource PricedResource) Price { return resource.Hourly },
+	},
+	{
+		suffix: "monthly",
+		help:   "The cost of the resource %s per month",
+		value:  func(resource PricedResource) Price { return resource.Monthly },
+	},
+}
+
+// listPriceFamilies are exposed in addition to pricedResourceFamilies, if prices are adjusted.
+var listPriceFamilies = []metricFamily{
+	{
+		suffix: "list_hourly",
+		help:   "The list price of the resource %s per hour, before adjustments",
+		value:  func(resource PricedResource) Price { return resource.ListHourly },
+	},
+	{
+		suffix: "list_monthly",
+		help:   "The list price of the resource %s per month, before adjustments",
+		value:  func(resource PricedResource) Price { return resource.ListMonthly },
+	},
+}
+
+// snapshotCollector exposes the last published snapshot of a fetcher.
+type snapshotCollector struct {
+	priceTypes  []PriceType
+	families    []metricFamily
+	familyDescs []*prometheus.Desc
+	accruedDesc *prometheus.Desc
+	staleDesc   *prometheus.Desc
+	updatedDesc *prometheus.Desc
+}
+
+func newSnapshotCollector(resource string, labels []string, priceType PriceType, adjusted bool) snapshotCollector {
+	families := pricedResourceFamilies
+	if adjusted {
+		families = slices.Concat(pricedResourceFamilies, listPriceFamilies)
+	}
+
+	priceTypes := priceType.exposed()
+	labels = priceLabels(labels, priceTypes)
+
+	familyDescs := make([]*prometheus.Desc, len(families))
+	for i, family := range families {
+		familyDescs[i] = prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", fmt.Sprintf("%s_%s", resource, family.suffix)),
+			fmt.Sprintf(family.help, resource),
+			labels,
+			nil,
+		)
+	}
+
+	resourceLabels := prometheus.Labels{"resource": resource}
+	return snapshotCollector{
+		priceTypes:  priceTypes,
+		families:    families,
+		familyDescs: familyDescs,
+		accruedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", resource+"_month_to_date"),
+			fmt.Sprintf("The cost that the resource %s accrued in the current month, capped at its monthly price", resource),
+			labels,
+			nil,
+		),
+		staleDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", "stale"),
+			"Whether the exposed costs of a resource type are outdated, because the last fetching cycle failed",
+			nil,
+			resourceLabels,
+		),
+		updatedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", "updated_timestamp_seconds"),
+			"The point in time at which the exposed costs of a resource type were collected",
+			nil,
+			resourceLabels,
+		),
+	}
+}
+
+func (collector snapshotCollector) describe(descs chan<- *prometheus.Desc) {
+	for _, desc := range collector.familyDescs {
+		descs <- desc
+	}
+	descs <- collector.accruedDesc
+	descs <- collector.staleDesc
+	descs <- collector.updatedDesc
+}
+
+func (collector snapshotCollector) collect(snapshot *Snapshot, metrics chan<- prometheus.Metric) {
+	for i, family := range collector.families {
+		for _, resource := range snapshot.Resources {
+			collectPrice(collector.familyDescs[i], resource.Labels, family.value(resource), collector.priceTypes,
+				snapshot.Currency, metrics)
+		}
+	}
+	// Resources that took over the labels of a deleted one are exposed as a single series. The accrued costs are
+	// ordered by their labels, so those are next to each other.
+	for i := 0; i < len(snapshot.Accrued); {
+		labels, cost := snapshot.Accrued[i].Labels, snapshot.Accrued[i].Cost
+		for i++; i < len(snapshot.Accrued) && slices.Equal(snapshot.Accrued[i].Labels, labels); i++ {
+			cost = cost.plus(snapshot.Accrued[i].Cost)
+		}
+		collectPrice(collector.accruedDesc, labels, cost, collector.priceTypes, snapshot.Currency, metrics)
+	}
+
+	stale := 0.0
+	if snapshot.Stale {
+		stale = 1
+	}
+	metrics <- prometheus.MustNewConstMetric(collector.staleDesc, prometheus.GaugeValue, stale)
+
+	if !snapshot.Timestamp.IsZero() {
+		metrics <- prometheus.MustNewConstMetric(
+			collector.updatedDesc,
+			prometheus.GaugeValue,
+			float64(snapshot.Timestamp.UnixNano())/float64(time.Second),
+		)
+	}
+}
+
+// priceLabels appends the names of the labels that distinguish the exposed prices to the passed label names. The price
+// type is only a label, if more than one price type is exposed.
+func priceLabels(labels []string, priceTypes []PriceType) []string {
+	labels = slices.Clone(labels)
+	if len(priceTypes) > 1 {
+		labels = append(labels, PriceTypeLabel)
+	}
+	return append(labels, CurrencyLabel)
+}
+
+// collectPrice exposes a series for every passed price type of the price, whose label values are followed by the ones
+// of the labels of priceLabels.
+func collectPrice(
+	desc *prometheus.Desc, labels []string, price Price, priceTypes []PriceType, currency string,
+	metrics chan<- prometheus.Metric,
+) {
+	for _, priceType := range priceTypes {
+		values := slices.Clone(labels)
+		if len(priceTypes) > 1 {
+			values = append(values, string(priceType))
+		}
+		values = append(values, currency)
+
+		metrics <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, price.of(priceType), values...)
+	}
+}
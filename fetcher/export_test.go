@@ -0,0 +1,11 @@
+package fetcher
+
+import "time"
+
+// SetClock replaces the clock of the provider, so that tests can travel in time.
+func SetClock(provider *PriceProvider, now func() time.Time) {
+	provider.pricingLock.Lock()
+	defer provider.pricingLock.Unlock()
+
+	provider.now = now
+}
@@ -2,91 +2,323 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	ctx = context.Background()
-)
-
 // Fetcher defines a common interface for types that fetch pricing data from the HCloud API.
 type Fetcher interface {
-	// GetHourly returns the prometheus collector that collects pricing data for hourly expenses.
-	GetHourly() *prometheus.GaugeVec
-	// GetMonthly returns the prometheus collector that collects pricing data for monthly expenses.
-	GetMonthly() *prometheus.GaugeVec
-	// Run executes a new data fetching cycle and updates the prometheus exposed collectors.
-	Run(*hcloud.Client) error
+	prometheus.Collector
+
+	// Name returns the name of the resource type that the fetcher collects costs for.
+	Name() string
+	// Snapshot returns the last published snapshot of priced resources.
+	Snapshot() *Snapshot
+	// Run executes a new data fetching cycle and publishes the collected data, once the cycle succeeded.
+	Run(context.Context, *Inventory) error
+	// MarkStale flags the last published data as outdated, because a data fetching cycle failed.
+	MarkStale()
 }
 
 type baseFetcher struct {
+	resource         string
 	pricing          *PriceProvider
-	hourly           *prometheus.GaugeVec
-	monthly          *prometheus.GaugeVec
 	additionalLabels []string
+	labels           []string
+	collector        snapshotCollector
+	accrual          *accrual
+
+	publishLock sync.RWMutex
+	snapshot    *Snapshot
+
+	// running is set while a data fetching cycle of the fetcher is in-flight.
+	running atomic.Bool
+}
+
+func (fetcher *baseFetcher) Name() string {
+	return fetcher.resource
+}
+
+func (fetcher *baseFetcher) Snapshot() *Snapshot {
+	fetcher.publishLock.RLock()
+	defer fetcher.publishLock.RUnlock()
+
+	return fetcher.snapshot
+}
+
+func (fetcher *baseFetcher) MarkStale() {
+	fetcher.publishLock.Lock()
+	defer fetcher.publishLock.Unlock()
+
+	stale := *fetcher.snapshot
+	stale.Stale = true
+	fetcher.snapshot = &stale
 }
 
-func (fetcher baseFetcher) GetHourly() *prometheus.GaugeVec {
-	return fetcher.hourly
+// backdate flags the published snapshot as outdated and dates it back to the passed point in time.
+func (fetcher *baseFetcher) backdate(timestamp time.Time) {
+	fetcher.publishLock.Lock()
+	defer fetcher.publishLock.Unlock()
+
+	restored := *fetcher.snapshot
+	restored.Timestamp = timestamp
+	restored.Stale = true
+	fetcher.snapshot = &restored
+}
+
+func (fetcher *baseFetcher) Describe(descs chan<- *prometheus.Desc) {
+	fetcher.collector.describe(descs)
+}
+
+func (fetcher *baseFetcher) Collect(metrics chan<- prometheus.Metric) {
+	fetcher.collector.collect(fetcher.Snapshot(), metrics)
+}
+
+// publish replaces the exposed snapshot with the passed one in a single step. Snapshots without a currency are in the
+// currency of the pricing, the costs of snapshots without accrued costs are accrued up to now.
+func (fetcher *baseFetcher) publish(snapshot *Snapshot) {
+	now := time.Now()
+	if fetcher.pricing != nil {
+		if snapshot.Currency == "" {
+			snapshot.Currency = fetcher.pricing.currency()
+		}
+		now = fetcher.pricing.clock()
+	}
+	if snapshot.Accrued == nil {
+		snapshot.Accrued = fetcher.accrual.update(snapshot, now)
+	}
+
+	fetcher.publishLock.Lock()
+	defer fetcher.publishLock.Unlock()
+
+	fetcher.snapshot = snapshot
 }
 
-func (fetcher baseFetcher) GetMonthly() *prometheus.GaugeVec {
-	return fetcher.monthly
+// record adds the costs of a resource to the snapshot, along with its list prices, and applies the adjustments of the
+// pricing that match the resource.
+func (fetcher *baseFetcher) record(
+	snapshot *Snapshot, info resourceInfo, labels []string, hourly, monthly Price,
+) {
+	adjustedHourly, adjustedMonthly := fetcher.pricing.adjust(fetcher.resource, info, hourly, monthly)
+	snapshot.add(PricedResource{
+		Labels:      labels,
+		Hourly:      adjustedHourly,
+		Monthly:     adjustedMonthly,
+		ListHourly:  hourly,
+		ListMonthly: monthly,
+		ID:          info.id,
+		Created:     info.created,
+	})
+}
+
+// inherit exposes the snapshot of the passed fetcher, if it collects the same resource with the same labels. The
+// snapshot is shared as is, since the passed fetcher may still expose it.
+func (fetcher *baseFetcher) inherit(previous *baseFetcher) {
+	if fetcher.resource != previous.resource || !slices.Equal(fetcher.labels, previous.labels) ||
+		!slices.Equal(fetcher.collector.priceTypes, previous.collector.priceTypes) ||
+		len(fetcher.collector.families) != len(previous.collector.families) {
+		return
+	}
+
+	snapshot := previous.Snapshot()
+	fetcher.publishLock.Lock()
+	defer fetcher.publishLock.Unlock()
+
+	fetcher.accrual = previous.accrual
+	fetcher.snapshot = snapshot
+}
+
+// basedFetcher is implemented by the fetchers that are built on top of baseFetcher.
+type basedFetcher interface {
+	base() *baseFetcher
+}
+
+func (fetcher *baseFetcher) base() *baseFetcher {
+	return fetcher
 }
 
 func newBase(pricing *PriceProvider, resource string, baselabels []string, additionalLabels ...string) *baseFetcher {
 	labels := append([]string{"name"}, baselabels...)
 	labels = append(labels, additionalLabels...)
-
-	hourlyGaugeOpts := prometheus.GaugeOpts{
-		Namespace: "hcloud",
-		Subsystem: "pricing",
-		Name:      fmt.Sprintf("%s_hourly", resource),
-		Help:      fmt.Sprintf("The cost of the resource %s per hour", resource),
-	}
-	monthlyGaugeOpts := prometheus.GaugeOpts{
-		Namespace: "hcloud",
-		Subsystem: "pricing",
-		Name:      fmt.Sprintf("%s_monthly", resource),
-		Help:      fmt.Sprintf("The cost of the resource %s per month", resource),
+	var priceType PriceType
+	var adjusted bool
+	if pricing != nil {
+		priceType = pricing.PriceType
+		adjusted = len(pricing.Adjustments) > 0
 	}
 
 	return &baseFetcher{
+		resource:         resource,
 		pricing:          pricing,
-		hourly:           prometheus.NewGaugeVec(hourlyGaugeOpts, labels),
-		monthly:          prometheus.NewGaugeVec(monthlyGaugeOpts, labels),
 		additionalLabels: additionalLabels,
+		labels:           labels,
+		collector:        newSnapshotCollector(resource, labels, priceType, adjusted),
+		accrual:          newAccrual(),
+		snapshot:         &Snapshot{},
 	}
 }
 
 // Fetchers defines a type for a slice of fetchers that should be handled together.
 type Fetchers []Fetcher
 
-// RegisterCollectors registers all collectors of the contained fetchers into the passed registry.
-func (fetchers Fetchers) RegisterCollectors(registry *prometheus.Registry) {
+// RegisterCollectors registers all collectors of the contained fetchers into the passed registry, along with the
+// forecast of the costs of all their resources until the end of the month. It fails, if a collector is invalid, e.g.
+// because an additional label duplicates a label of a fetcher.
+func (fetchers Fetchers) RegisterCollectors(registry prometheus.Registerer) error {
+	for _, fetcher := range fetchers {
+		if err := registry.Register(fetcher); err != nil {
+			return fmt.Errorf("failed to register the %s fetcher: %w", fetcher.Name(), err)
+		}
+	}
+	if err := registry.Register(newForecast(fetchers)); err != nil {
+		return fmt.Errorf("failed to register the forecast: %w", err)
+	}
+	return nil
+}
+
+// Inherit takes over the published snapshots of the previous fetchers, so that a replaced set of fetchers exposes the
+// same data until its first data fetching cycle completes. Fetchers whose labels changed start out empty.
+func (fetchers Fetchers) Inherit(previous Fetchers) {
 	for _, fetcher := range fetchers {
-		registry.MustRegister(
-			fetcher.GetHourly(),
-			fetcher.GetMonthly(),
-		)
+		current, ok := fetcher.(basedFetcher)
+		if !ok {
+			continue
+		}
+
+		for _, candidate := range previous {
+			if candidate, ok := candidate.(basedFetcher); ok {
+				current.base().inherit(candidate.base())
+			}
+		}
 	}
 }
 
-// Run executes all contained fetchers and returns a single error, even when multiple failures occurred.
-func (fetchers Fetchers) Run(client *hcloud.Client) error {
-	errors := prometheus.MultiError{}
+// Restore publishes the costs of a restored inventory as stale snapshots, which carry the point in time the inventory
+// was persisted at. They are served until the first data fetching cycle completes, so the monitor is not informed.
+// Fetchers whose resources were not persisted stay empty.
+func (fetchers Fetchers) Restore(ctx context.Context, inventory *Inventory) {
+	for _, fetcher := range fetchers {
+		based, ok := fetcher.(basedFetcher)
+		if !ok {
+			continue
+		}
+
+		if err := fetcher.Run(ctx, inventory); err != nil {
+			log.Printf("Failed to restore costs of %s: %v", fetcher.Name(), err)
+			continue
+		}
+		based.base().backdate(inventory.restoredAt)
+	}
+}
+
+// RestoreAccrued takes over the costs that the resources of the fetchers accrued in the current month before the
+// exporter restarted. It must be called before the first data fetching cycle.
+func (fetchers Fetchers) RestoreAccrued(store *StateStore) error {
+	var errs []error
 	for _, fetcher := range fetchers {
-		fetcher.GetHourly().Reset()
-		fetcher.GetMonthly().Reset()
+		if based, ok := fetcher.(basedFetcher); ok {
+			errs = append(errs, based.base().accrual.restore(store, fetcher.Name(), based.base().labels))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveAccrued persists the costs that the resources of the fetchers accrued in the current month.
+func (fetchers Fetchers) saveAccrued(store *StateStore) {
+	if store == nil {
+		return
+	}
 
-		if err := fetcher.Run(client); err != nil {
-			errors.Append(err)
+	var errs []error
+	for _, fetcher := range fetchers {
+		if based, ok := fetcher.(basedFetcher); ok {
+			errs = append(errs, based.base().accrual.save(store, fetcher.Name(), based.base().labels))
 		}
 	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("Failed to persist accrued costs: %v", err)
+	}
+}
+
+// RunOptions defines how a fetching cycle of multiple fetchers is executed.
+type RunOptions struct {
+	// FetchTimeout is the maximum duration a single fetcher may take. Zero disables the deadline.
+	FetchTimeout time.Duration
+	// CycleTimeout is the maximum duration a whole fetching cycle may take. Zero disables the deadline.
+	CycleTimeout time.Duration
+	// Concurrency is the maximum number of fetchers that run in parallel. Zero runs all fetchers at once.
+	Concurrency int
+	// Monitor records the outcome of every fetcher, if set.
+	Monitor *Monitor
+	// RateLimiter reports whether the API request budget runs low, if set. In that case fetchers run one after
+	// another and the optional fetchers are skipped.
+	RateLimiter *RateLimiter
+	// Optional contains the names of fetchers that may be skipped to save API requests.
+	Optional []string
+	// State persists the resources that were listed during the cycle, if set.
+	State *StateStore
+}
+
+// Run executes all contained fetchers and returns a single error, even when multiple failures occurred.
+func (fetchers Fetchers) Run(ctx context.Context, client *hcloud.Client, opts RunOptions) error {
+	if opts.CycleTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.CycleTimeout)
+		defer cancel()
+	}
+
+	throttled := opts.RateLimiter != nil && opts.RateLimiter.Low()
+	if throttled {
+		log.Println("API request budget is running low, skipping optional fetchers")
+	}
+	concurrency := opts.concurrency(len(fetchers), throttled)
+
+	inventory := NewInventory(client)
+
+	// Every fetcher reports into its own slot, so the aggregated error keeps the order of the fetchers.
+	results := make([]error, len(fetchers))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, fetcher := range fetchers {
+		if throttled && slices.Contains(opts.Optional, fetcher.Name()) {
+			continue
+		}
+		release, ok := acquire(fetcher)
+		if !ok {
+			log.Printf("Skipping fetcher %s, its previous run has not finished yet", fetcher.Name())
+			if opts.Monitor != nil {
+				opts.Monitor.skip(fetcher)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, fetcher Fetcher) {
+			defer wg.Done()
+			defer release()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = runFetcher(ctx, inventory, fetcher, opts)
+		}(i, fetcher)
+	}
+	wg.Wait()
+	inventory.Save(opts.State)
+	fetchers.saveAccrued(opts.State)
+
+	errors := prometheus.MultiError{}
+	for _, err := range results {
+		errors.Append(err)
+	}
 
 	if len(errors) > 0 {
 		return errors
@@ -94,9 +326,60 @@ func (fetchers Fetchers) Run(client *hcloud.Client) error {
 	return nil
 }
 
-// MustRun executes all contained fetchers and panics if any of them threw an error.
-func (fetchers Fetchers) MustRun(client *hcloud.Client) {
-	if err := fetchers.Run(client); err != nil {
+// concurrency returns the number of fetchers out of the passed number that run in parallel. Throttled cycles run the
+// fetchers one after another.
+func (opts RunOptions) concurrency(fetchers int, throttled bool) int {
+	switch {
+	case throttled:
+		return 1
+	case opts.Concurrency <= 0 || opts.Concurrency > fetchers:
+		return fetchers
+	default:
+		return opts.Concurrency
+	}
+}
+
+// runFetcher executes a single fetcher, marks its data as stale if it fails and reports the outcome to the monitor.
+func runFetcher(ctx context.Context, inventory *Inventory, fetcher Fetcher, opts RunOptions) error {
+	start := time.Now()
+	err := runWithTimeout(ctx, inventory, fetcher, opts.FetchTimeout)
+	if err != nil {
+		fetcher.MarkStale()
+	}
+
+	if opts.Monitor != nil {
+		opts.Monitor.observe(fetcher, time.Since(start), err)
+	}
+	return err
+}
+
+// MustRun executes all contained fetchers and logs if any of them threw an error.
+func (fetchers Fetchers) MustRun(ctx context.Context, client *hcloud.Client, opts RunOptions) {
+	if err := fetchers.Run(ctx, client, opts); err != nil {
 		log.Println(err)
 	}
 }
+
+// acquire marks the fetcher as running, unless it already is. The returned function marks it as finished.
+func acquire(fetcher Fetcher) (release func(), ok bool) {
+	based, isBased := fetcher.(basedFetcher)
+	if !isBased {
+		return func() {}, true
+	}
+
+	running := &based.base().running
+	if !running.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	return func() { running.Store(false) }, true
+}
+
+func runWithTimeout(ctx context.Context, inventory *Inventory, fetcher Fetcher, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return fetcher.Run(ctx, inventory)
+}
@@ -0,0 +1,283 @@
+package fetcher_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For a fetching cycle", func() {
+	var (
+		api      *fakeAPI
+		client   *hcloud.Client
+		sut      fetcher.Fetcher
+		fetchers fetcher.Fetchers
+	)
+
+	BeforeEach(func() {
+		api, client = newFakeAPI(map[string]int{"volumes": 3})
+		sut = fetcher.NewVolume(&fetcher.PriceProvider{Client: client})
+		fetchers = fetcher.Fetchers{sut}
+	})
+
+	When("a resource is deleted", func() {
+		It("should no longer expose its costs", func(ctx context.Context) {
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(testutil.CollectAndCount(sut, "hcloud_pricing_volume_hourly")).To(Equal(3))
+
+			api.SetResources("volumes", 2)
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(testutil.CollectAndCount(sut, "hcloud_pricing_volume_hourly")).To(Equal(2))
+			Expect(sut.Snapshot().Find("volumes-3", "fsn1", "10")).To(BeNil())
+		})
+	})
+
+	When("a fetcher fails", func() {
+		It("should keep the last published values and mark them as stale", func(ctx context.Context) {
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(sut.Snapshot().Find("volumes-1", "fsn1", "10")).NotTo(BeNil())
+
+			api.Fail("volumes", true)
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).NotTo(Succeed())
+			Expect(sut.Snapshot().Find("volumes-1", "fsn1", "10").Monthly.Gross).Should(BeNumerically(">", 0.0))
+			Expect(testutil.CollectAndCount(sut, "hcloud_pricing_volume_monthly")).To(Equal(3))
+			Expect(testutil.CollectAndCompare(sut, staleMetric("volume", 1), "hcloud_pricing_stale")).To(Succeed())
+
+			api.Fail("volumes", false)
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(testutil.CollectAndCompare(sut, staleMetric("volume", 0), "hcloud_pricing_stale")).To(Succeed())
+		})
+	})
+
+	When("a fetcher hangs", func() {
+		BeforeEach(func(ctx context.Context) {
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			DeferCleanup(api.Block("volumes"))
+		})
+
+		DescribeTable("should give up after the deadline and mark its values as stale",
+			func(ctx context.Context, opts fetcher.RunOptions) {
+				Expect(fetchers.Run(ctx, client, opts)).To(MatchError(ContainSubstring("deadline exceeded")))
+				Expect(testutil.CollectAndCompare(sut, staleMetric("volume", 1), "hcloud_pricing_stale")).To(Succeed())
+			},
+			Entry("of the fetcher", fetcher.RunOptions{FetchTimeout: 50 * time.Millisecond}),
+			Entry("of the cycle", fetcher.RunOptions{CycleTimeout: 50 * time.Millisecond}),
+		)
+	})
+
+	When("several fetchers run", func() {
+		var pair fetcher.Fetchers
+
+		BeforeEach(func() {
+			api.SetResources("floating_ips", 1)
+			pricing := &fetcher.PriceProvider{Client: client}
+			pair = fetcher.Fetchers{fetcher.NewVolume(pricing), fetcher.NewFloatingIP(pricing)}
+		})
+
+		inFlight := func() int { return api.Requests("volumes") + api.Requests("floating_ips") }
+
+		DescribeTable("should run at most as many in parallel as allowed",
+			func(ctx context.Context, concurrency int) {
+				releaseVolumes, releaseFloatingIPs := api.Block("volumes"), api.Block("floating_ips")
+				done := make(chan error)
+				go func() { done <- pair.Run(ctx, client, fetcher.RunOptions{Concurrency: concurrency}) }()
+
+				Eventually(inFlight).Should(Equal(concurrency))
+				Consistently(inFlight, "50ms").Should(Equal(concurrency))
+
+				releaseVolumes()
+				releaseFloatingIPs()
+				Eventually(done).Should(Receive(BeNil()))
+				Expect(inFlight()).To(Equal(2))
+			},
+			Entry("one after another", 1),
+			Entry("both at once", 2),
+		)
+
+		It("should aggregate their errors in the order of the fetchers", func(ctx context.Context) {
+			api.Fail("volumes", true)
+			api.Fail("floating_ips", true)
+
+			err := pair.Run(ctx, client, fetcher.RunOptions{Concurrency: 2})
+			var errs prometheus.MultiError
+			Expect(err).To(BeAssignableToTypeOf(errs))
+			errs = err.(prometheus.MultiError)
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0]).To(MatchError(ContainSubstring("volumes")))
+			Expect(errs[1]).To(MatchError(ContainSubstring("floating IPs")))
+		})
+	})
+
+	When("net prices are selected", func() {
+		It("should expose them without a price type", func(ctx context.Context) {
+			api.SetResources("volumes", 1)
+			net := fetcher.NewVolume(&fetcher.PriceProvider{Client: client, PriceType: fetcher.PriceTypeNet})
+			Expect(fetcher.Fetchers{net}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			Expect(monthlyCosts(net, "volume")).To(HaveKeyWithValue("", BeNumerically("~", 0.44, 1e-6)))
+		})
+	})
+
+	When("both price types are selected", func() {
+		It("should distinguish them by a label", func(ctx context.Context) {
+			api.SetResources("volumes", 1)
+			both := fetcher.NewVolume(&fetcher.PriceProvider{Client: client, PriceType: fetcher.PriceTypeBoth})
+			Expect(fetcher.Fetchers{both}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			costs := monthlyCosts(both, "volume")
+			Expect(costs).To(HaveLen(2))
+			Expect(costs).To(HaveKeyWithValue("net", BeNumerically("~", 0.44, 1e-6)))
+			Expect(costs).To(HaveKeyWithValue("gross", BeNumerically("~", 0.5236, 1e-6)))
+		})
+
+		It("should not inherit the snapshot of fetchers without the label", func(ctx context.Context) {
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			replacement := fetcher.NewVolume(&fetcher.PriceProvider{Client: client, PriceType: fetcher.PriceTypeBoth})
+			fetcher.Fetchers{replacement}.Inherit(fetchers)
+
+			Expect(testutil.CollectAndCount(replacement, "hcloud_pricing_volume_hourly")).To(BeZero())
+		})
+	})
+
+	When("a monitor is attached", func() {
+		It("should record the outcome of every fetcher", func(ctx context.Context) {
+			monitor := fetcher.NewMonitor(&fetcher.PriceProvider{Client: client})
+			opts := fetcher.RunOptions{Monitor: monitor}
+
+			Expect(fetchers.Run(ctx, client, opts)).To(Succeed())
+			api.Fail("volumes", true)
+			Expect(fetchers.Run(ctx, client, opts)).NotTo(Succeed())
+
+			Expect(testutil.CollectAndCompare(monitor, strings.NewReader(`
+# HELP hcloud_pricing_exporter_fetch_errors_total The number of failed data fetching cycles per fetcher
+# TYPE hcloud_pricing_exporter_fetch_errors_total counter
+hcloud_pricing_exporter_fetch_errors_total{fetcher="volume"} 1
+# HELP hcloud_pricing_exporter_resources The number of priced resources per resource type
+# TYPE hcloud_pricing_exporter_resources gauge
+hcloud_pricing_exporter_resources{type="volume"} 3
+`), "hcloud_pricing_exporter_fetch_errors_total", "hcloud_pricing_exporter_resources")).To(Succeed())
+			Expect(testutil.CollectAndCount(monitor, "hcloud_pricing_exporter_last_success_timestamp_seconds")).To(Equal(1))
+		})
+
+		It("should report readiness once every fetcher succeeded", func(ctx context.Context) {
+			monitor := fetcher.NewMonitor(&fetcher.PriceProvider{Client: client})
+			opts := fetcher.RunOptions{Monitor: monitor}
+			Expect(monitor.Readiness(time.Hour).Ready).To(BeFalse())
+
+			Expect(fetchers.Run(ctx, client, opts)).To(Succeed())
+			Expect(monitor.Readiness(time.Hour).Ready).To(BeTrue())
+
+			api.Fail("volumes", true)
+			Expect(fetchers.Run(ctx, client, opts)).NotTo(Succeed())
+			Expect(monitor.Readiness(time.Hour).Ready).To(BeTrue())
+
+			readiness := monitor.Readiness(time.Nanosecond)
+			Expect(readiness.Ready).To(BeFalse())
+			Expect(readiness.Fetchers).To(HaveKey("volume"))
+			Expect(readiness.Fetchers["volume"].LastError).To(ContainSubstring("fake outage"))
+		})
+	})
+
+	When("the fetchers are replaced", func() {
+		It("should expose the data of their predecessors until they ran", func(ctx context.Context) {
+			monitor := fetcher.NewMonitor(&fetcher.PriceProvider{Client: client})
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{Monitor: monitor})).To(Succeed())
+
+			replacement := fetcher.NewVolume(&fetcher.PriceProvider{Client: client})
+			replacementMonitor := fetcher.NewMonitor(&fetcher.PriceProvider{Client: client})
+			fetcher.Fetchers{replacement}.Inherit(fetchers)
+			replacementMonitor.Inherit(monitor, fetcher.Fetchers{replacement})
+
+			Expect(replacement.Snapshot()).To(BeIdenticalTo(sut.Snapshot()))
+			Expect(testutil.CollectAndCount(replacement, "hcloud_pricing_volume_hourly")).To(Equal(3))
+			Expect(replacementMonitor.Readiness(time.Hour).Ready).To(BeTrue())
+			Expect(testutil.CollectAndCompare(replacementMonitor, strings.NewReader(`
+# HELP hcloud_pricing_exporter_resources The number of priced resources per resource type
+# TYPE hcloud_pricing_exporter_resources gauge
+hcloud_pricing_exporter_resources{type="volume"} 3
+`), "hcloud_pricing_exporter_resources")).To(Succeed())
+			Expect(testutil.CollectAndCount(replacementMonitor, "hcloud_pricing_exporter_last_success_timestamp_seconds")).
+				To(Equal(1))
+		})
+
+		It("should not wait for fetchers that no longer run", func(ctx context.Context) {
+			_, client := newFakeAPI(map[string]int{"servers": 1, "volumes": 1})
+			pricing := &fetcher.PriceProvider{Client: client}
+			monitor := fetcher.NewMonitor(pricing)
+			previous := fetcher.Fetchers{fetcher.NewServer(pricing), fetcher.NewVolume(pricing)}
+			Expect(previous.Run(ctx, client, fetcher.RunOptions{Monitor: monitor})).To(Succeed())
+
+			replacementMonitor := fetcher.NewMonitor(pricing)
+			replacementMonitor.Inherit(monitor, fetcher.Fetchers{fetcher.NewServer(pricing)})
+
+			readiness := replacementMonitor.Readiness(time.Hour)
+			Expect(readiness.Fetchers).To(HaveKey("server"))
+			Expect(readiness.Fetchers).NotTo(HaveKey("volume"))
+		})
+
+		It("should leave the snapshots of their predecessors untouched", func(ctx context.Context) {
+			pricing := &fetcher.PriceProvider{Client: client}
+			previous := fetcher.NewVolume(pricing)
+
+			replacement := fetcher.NewVolume(pricing)
+			fetcher.Fetchers{replacement}.Inherit(fetcher.Fetchers{previous})
+
+			Expect(replacement.Snapshot()).To(BeIdenticalTo(previous.Snapshot()))
+			Expect(previous.Snapshot().Currency).To(BeEmpty())
+			Expect(previous.Snapshot().Accrued).To(BeNil())
+		})
+
+		It("should start out empty, if the labels changed", func(ctx context.Context) {
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+			replacement := fetcher.NewVolume(&fetcher.PriceProvider{Client: client}, "team")
+			fetcher.Fetchers{replacement}.Inherit(fetchers)
+
+			Expect(testutil.CollectAndCount(replacement, "hcloud_pricing_volume_hourly")).To(BeZero())
+		})
+	})
+})
+
+// monthlyCosts gathers the exposed monthly costs of the fetcher by their price type, which is empty if it is not
+// exposed as a label.
+func monthlyCosts(sut fetcher.Fetcher, resource string) map[string]float64 {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(sut)
+	families, err := registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	costs := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "hcloud_pricing_"+resource+"_monthly" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			priceType := ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == fetcher.PriceTypeLabel {
+					priceType = label.GetValue()
+				}
+			}
+			costs[priceType] = metric.GetGauge().GetValue()
+		}
+	}
+	return costs
+}
+
+func staleMetric(resource string, value int) io.Reader {
+	return strings.NewReader(fmt.Sprintf(`
+# HELP hcloud_pricing_stale Whether the exposed costs of a resource type are outdated, because the last fetching cycle failed
+# TYPE hcloud_pricing_stale gauge
+hcloud_pricing_stale{resource="%s"} %d
+`, resource, value))
+}
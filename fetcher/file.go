@@ -0,0 +1,57 @@
+package fetcher
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchedFile parses a local file and parses it again, whenever its modification time or size changes.
+type watchedFile[T any] struct {
+	path  string
+	kind  string
+	parse func(path string) (T, error)
+
+	lock    sync.Mutex
+	modTime time.Time
+	size    int64
+	loaded  bool
+	value   T
+	err     error
+}
+
+func newWatchedFile[T any](path, kind string, parse func(path string) (T, error)) *watchedFile[T] {
+	return &watchedFile[T]{path: path, kind: kind, parse: parse}
+}
+
+// get returns the parsed content of the file and parses it again, if it changed since the last read. If the changed
+// file cannot be parsed, the previous content is returned along with the error.
+func (file *watchedFile[T]) get() (T, error) {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+
+	info, err := os.Stat(file.path)
+	if err == nil && file.loaded && info.ModTime().Equal(file.modTime) && info.Size() == file.size {
+		return file.value, file.err
+	}
+
+	var value T
+	if err == nil {
+		file.modTime, file.size = info.ModTime(), info.Size()
+		value, err = file.parse(file.path)
+	}
+	if err != nil {
+		if file.loaded && (file.err == nil || file.err.Error() != err.Error()) {
+			log.Printf("Failed to read %s %s, keeping the previous one: %v", file.kind, file.path, err)
+		}
+		file.err = err
+		return file.value, err
+	}
+
+	if file.loaded {
+		log.Printf("Reloaded %s %s", file.kind, file.path)
+	}
+	file.value, file.loaded, file.err = value, true, nil
+	return file.value, nil
+}
@@ -1,10 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &floatingIP{}
@@ -18,16 +17,17 @@ type floatingIP struct {
 	*baseFetcher
 }
 
-func (floatingIP floatingIP) Run(client *hcloud.Client) error {
-	floatingIPs, _, err := client.FloatingIP.List(ctx, hcloud.FloatingIPListOpts{})
+func (floatingIP floatingIP) Run(ctx context.Context, inventory *Inventory) error {
+	floatingIPs, err := inventory.FloatingIPs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list floating IPs: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, f := range floatingIPs {
 		location := f.HomeLocation
 
-		monthlyPrice, err := floatingIP.pricing.FloatingIP(f.Type, location.Name)
+		monthlyPrice, err := floatingIP.pricing.FloatingIP(ctx, f.Type, location.Name)
 		if err != nil {
 			log.Printf("Could not get floating IP pricing for %s (%s, %s): %v", f.Name, f.Type, location.Name, err)
 			return fmt.Errorf("could not get floating IP pricing for %s (%s, %s): %w", f.Name, f.Type, location.Name, err)
@@ -42,9 +42,10 @@ func (floatingIP floatingIP) Run(client *hcloud.Client) error {
 			parseAdditionalLabels(floatingIP.additionalLabels, f.Labels)...,
 		)
 
-		floatingIP.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-		floatingIP.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+		info := resourceInfo{id: f.ID, location: location.Name, labels: f.Labels, created: f.Created}
+		floatingIP.record(result, info, labels, hourlyPrice, monthlyPrice)
 	}
 
+	floatingIP.publish(result)
 	return nil
 }
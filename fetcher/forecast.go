@@ -0,0 +1,69 @@
+package fetcher
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// forecast exposes the costs that the resources of all fetchers are expected to accrue until the end of the month, per
+// resource type and in total.
+type forecast struct {
+	fetchers     Fetchers
+	priceTypes   []PriceType
+	resourceDesc *prometheus.Desc
+	totalDesc    *prometheus.Desc
+}
+
+func newForecast(fetchers Fetchers) *forecast {
+	priceTypes := PriceTypeGross.exposed()
+	for _, fetcher := range fetchers {
+		if based, ok := fetcher.(basedFetcher); ok {
+			priceTypes = based.base().collector.priceTypes
+			break
+		}
+	}
+
+	labels := priceLabels(nil, priceTypes)
+
+	return &forecast{
+		fetchers:   fetchers,
+		priceTypes: priceTypes,
+		resourceDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", "forecast_month"),
+			"The cost that the resources of a type are expected to accrue in the current month, at their current hourly prices",
+			append([]string{"resource"}, labels...),
+			nil,
+		),
+		totalDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing", "forecast_month_total"),
+			"The cost that all resources are expected to accrue in the current month, at their current hourly prices",
+			labels,
+			nil,
+		),
+	}
+}
+
+func (forecast *forecast) Describe(descs chan<- *prometheus.Desc) {
+	descs <- forecast.resourceDesc
+	descs <- forecast.totalDesc
+}
+
+func (forecast *forecast) Collect(metrics chan<- prometheus.Metric) {
+	var total Price
+	var currency string
+	for _, fetcher := range forecast.fetchers {
+		snapshot := fetcher.Snapshot()
+		if snapshot.Currency == "" {
+			continue
+		}
+		currency = snapshot.Currency
+
+		var sum Price
+		for _, accrued := range snapshot.Accrued {
+			sum = sum.plus(accrued.Forecast)
+		}
+		total = total.plus(sum)
+		collectPrice(forecast.resourceDesc, []string{fetcher.Name()}, sum, forecast.priceTypes, currency, metrics)
+	}
+
+	if currency != "" {
+		collectPrice(forecast.totalDesc, nil, total, forecast.priceTypes, currency, metrics)
+	}
+}
@@ -0,0 +1,212 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+const (
+	listPageSize = 50
+)
+
+var errNotPersisted = errors.New("resources were not persisted by a previous run")
+
+// Inventory provides the HCloud resources that are visible during a single fetching cycle. Each resource type is
+// listed at most once per inventory, no matter how many fetchers read it.
+type Inventory struct {
+	client *hcloud.Client
+
+	servers       cachedList[*hcloud.Server]
+	loadBalancers cachedList[*hcloud.LoadBalancer]
+	volumes       cachedList[*hcloud.Volume]
+	floatingIPs   cachedList[*hcloud.FloatingIP]
+	primaryIPs    cachedList[*hcloud.PrimaryIP]
+	images        cachedList[*hcloud.Image]
+
+	// restoredAt is the point in time at which the oldest list of a restored inventory was persisted.
+	restoredAt time.Time
+}
+
+// NewInventory creates an empty inventory that lists resources through the passed client on first access.
+func NewInventory(client *hcloud.Client) *Inventory {
+	return &Inventory{client: client}
+}
+
+// Servers returns all servers of the project.
+func (inventory *Inventory) Servers(ctx context.Context) ([]*hcloud.Server, error) {
+	return inventory.servers.get(ctx, func(ctx context.Context) ([]*hcloud.Server, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.Server, *hcloud.Response, error) {
+			return inventory.client.Server.List(ctx, hcloud.ServerListOpts{ListOpts: opts})
+		})
+	})
+}
+
+// LoadBalancers returns all load balancers of the project.
+func (inventory *Inventory) LoadBalancers(ctx context.Context) ([]*hcloud.LoadBalancer, error) {
+	return inventory.loadBalancers.get(ctx, func(ctx context.Context) ([]*hcloud.LoadBalancer, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.LoadBalancer, *hcloud.Response, error) {
+			return inventory.client.LoadBalancer.List(ctx, hcloud.LoadBalancerListOpts{ListOpts: opts})
+		})
+	})
+}
+
+// Volumes returns all volumes of the project.
+func (inventory *Inventory) Volumes(ctx context.Context) ([]*hcloud.Volume, error) {
+	return inventory.volumes.get(ctx, func(ctx context.Context) ([]*hcloud.Volume, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.Volume, *hcloud.Response, error) {
+			return inventory.client.Volume.List(ctx, hcloud.VolumeListOpts{ListOpts: opts})
+		})
+	})
+}
+
+// FloatingIPs returns all floating IPs of the project.
+func (inventory *Inventory) FloatingIPs(ctx context.Context) ([]*hcloud.FloatingIP, error) {
+	return inventory.floatingIPs.get(ctx, func(ctx context.Context) ([]*hcloud.FloatingIP, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.FloatingIP, *hcloud.Response, error) {
+			return inventory.client.FloatingIP.List(ctx, hcloud.FloatingIPListOpts{ListOpts: opts})
+		})
+	})
+}
+
+// PrimaryIPs returns all primary IPs of the project.
+func (inventory *Inventory) PrimaryIPs(ctx context.Context) ([]*hcloud.PrimaryIP, error) {
+	return inventory.primaryIPs.get(ctx, func(ctx context.Context) ([]*hcloud.PrimaryIP, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.PrimaryIP, *hcloud.Response, error) {
+			return inventory.client.PrimaryIP.List(ctx, hcloud.PrimaryIPListOpts{ListOpts: opts})
+		})
+	})
+}
+
+// Images returns all images that are visible to the project.
+func (inventory *Inventory) Images(ctx context.Context) ([]*hcloud.Image, error) {
+	return inventory.images.get(ctx, func(ctx context.Context) ([]*hcloud.Image, error) {
+		return listAll(func(opts hcloud.ListOpts) ([]*hcloud.Image, *hcloud.Response, error) {
+			// Only snapshots are billed, so system and app images are not listed at all.
+			return inventory.client.Image.List(ctx, hcloud.ImageListOpts{
+				ListOpts: opts,
+				Type:     []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+			})
+		})
+	})
+}
+
+// Save persists all lists of the inventory that were loaded successfully. Lists that were not needed or failed keep
+// their previously persisted state.
+func (inventory *Inventory) Save(store *StateStore) {
+	errs := []error{
+		saveList(store, "servers", &inventory.servers),
+		saveList(store, "load_balancers", &inventory.loadBalancers),
+		saveList(store, "volumes", &inventory.volumes),
+		saveList(store, "floating_ips", &inventory.floatingIPs),
+		saveList(store, "primary_ips", &inventory.primaryIPs),
+		saveList(store, "images", &inventory.images),
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("Failed to persist inventory: %v", err)
+	}
+}
+
+// RestoreInventory creates an inventory from the lists that were persisted to the passed store. It never calls the
+// API, lists that were not persisted cannot be read from it. The returned flag is false, if nothing was persisted.
+func RestoreInventory(store *StateStore) (*Inventory, bool, error) {
+	inventory := &Inventory{}
+	restored := []bool{}
+	errs := []error{}
+	restore := func(ok bool, savedAt time.Time, err error) {
+		restored = append(restored, ok)
+		errs = append(errs, err)
+		if ok && (inventory.restoredAt.IsZero() || savedAt.Before(inventory.restoredAt)) {
+			inventory.restoredAt = savedAt
+		}
+	}
+
+	restore(restoreList(store, "servers", &inventory.servers))
+	restore(restoreList(store, "load_balancers", &inventory.loadBalancers))
+	restore(restoreList(store, "volumes", &inventory.volumes))
+	restore(restoreList(store, "floating_ips", &inventory.floatingIPs))
+	restore(restoreList(store, "primary_ips", &inventory.primaryIPs))
+	restore(restoreList(store, "images", &inventory.images))
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, false, err
+	}
+	return inventory, !inventory.restoredAt.IsZero(), nil
+}
+
+func saveList[T any](store *StateStore, name string, list *cachedList[T]) error {
+	list.lock.Lock()
+	items, loaded := list.items, list.loaded
+	list.lock.Unlock()
+
+	if !loaded {
+		return nil
+	}
+	return saveState(store, name, items)
+}
+
+func restoreList[T any](store *StateStore, name string, list *cachedList[T]) (bool, time.Time, error) {
+	items, savedAt, ok, err := loadState[[]T](store, name)
+	if err != nil || !ok {
+		list.unavailable = errNotPersisted
+		return false, savedAt, err
+	}
+
+	list.items = items
+	list.loaded = true
+	return true, savedAt, nil
+}
+
+// listAll follows the pagination of a listing endpoint until the last page has been read.
+func listAll[T any](list func(hcloud.ListOpts) ([]T, *hcloud.Response, error)) ([]T, error) {
+	var result []T
+
+	opts := hcloud.ListOpts{Page: 1, PerPage: listPageSize}
+	for {
+		items, response, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+
+		if response == nil || response.Meta.Pagination == nil || response.Meta.Pagination.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = response.Meta.Pagination.NextPage
+	}
+}
+
+// cachedList holds the result of a single listing call. Failed listings are not cached, so that the next reader can
+// retry them with its own deadline.
+type cachedList[T any] struct {
+	lock   sync.Mutex
+	items  []T
+	loaded bool
+	// unavailable is returned instead of listing the resources, e.g. by restored inventories.
+	unavailable error
+}
+
+func (list *cachedList[T]) get(ctx context.Context, fetch func(context.Context) ([]T, error)) ([]T, error) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	if list.loaded {
+		return list.items, nil
+	}
+	if list.unavailable != nil {
+		return nil, list.unavailable
+	}
+
+	items, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list.items = items
+	list.loaded = true
+	return items, nil
+}
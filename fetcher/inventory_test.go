@@ -0,0 +1,69 @@
+package fetcher_test
+
+import (
+	"context"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("For the inventory", func() {
+	var (
+		api       *fakeAPI
+		inventory *fetcher.Inventory
+	)
+
+	BeforeEach(func() {
+		var client *hcloud.Client
+		api, client = newFakeAPI(map[string]int{
+			"servers":        35,
+			"load_balancers": 21,
+			"volumes":        30,
+			"floating_ips":   1,
+			"primary_ips":    0,
+			"images":         42,
+		})
+		inventory = fetcher.NewInventory(client)
+	})
+
+	When("listing resources", func() {
+		It("should follow the pagination of every resource type", func(ctx context.Context) {
+			servers, err := inventory.Servers(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(servers).To(HaveLen(35))
+			Expect(servers[34].Name).To(Equal("servers-35"))
+
+			loadBalancers, err := inventory.LoadBalancers(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadBalancers).To(HaveLen(21))
+
+			volumes, err := inventory.Volumes(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(volumes).To(HaveLen(30))
+
+			floatingIPs, err := inventory.FloatingIPs(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(floatingIPs).To(HaveLen(1))
+
+			primaryIPs, err := inventory.PrimaryIPs(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(primaryIPs).To(BeEmpty())
+
+			images, err := inventory.Images(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(HaveLen(42))
+			Expect(api.Query("images").Get("type")).To(Equal("snapshot"))
+		})
+
+		It("should request every page exactly once", func(ctx context.Context) {
+			for i := 0; i < 3; i++ {
+				_, err := inventory.Servers(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(api.Requests("servers")).To(Equal(4))
+		})
+	})
+})
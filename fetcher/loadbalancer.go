@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
@@ -17,12 +18,13 @@ type loadBalancer struct {
 	*baseFetcher
 }
 
-func (loadBalancer loadBalancer) Run(client *hcloud.Client) error {
-	loadBalancers, _, err := client.LoadBalancer.List(ctx, hcloud.LoadBalancerListOpts{})
+func (loadBalancer loadBalancer) Run(ctx context.Context, inventory *Inventory) error {
+	loadBalancers, err := inventory.LoadBalancers(ctx)
 	if err != nil {
 		return err
 	}
 
+	result := newSnapshot()
 	for _, lb := range loadBalancers {
 		location := lb.Location
 
@@ -34,24 +36,25 @@ func (loadBalancer loadBalancer) Run(client *hcloud.Client) error {
 			parseAdditionalLabels(loadBalancer.additionalLabels, lb.Labels)...,
 		)
 
-		pricing, err := findLBPricing(location, lb.LoadBalancerType.Pricings)
+		hourly, monthly, err := loadBalancer.pricing.LoadBalancer(ctx, lb.LoadBalancerType, location.Name)
 		if err != nil {
 			return err
 		}
 
-		parseToGauge(loadBalancer.hourly.WithLabelValues(labels...), pricing.Hourly.Gross)
-		parseToGauge(loadBalancer.monthly.WithLabelValues(labels...), pricing.Monthly.Gross)
+		info := resourceInfo{id: lb.ID, location: location.Name, labels: lb.Labels, created: lb.Created}
+		loadBalancer.record(result, info, labels, hourly, monthly)
 	}
 
+	loadBalancer.publish(result)
 	return nil
 }
 
-func findLBPricing(location *hcloud.Location, pricings []hcloud.LoadBalancerTypeLocationPricing) (*hcloud.LoadBalancerTypeLocationPricing, error) {
+func findLBPricing(location string, pricings []hcloud.LoadBalancerTypeLocationPricing) (*hcloud.LoadBalancerTypeLocationPricing, error) {
 	for _, pricing := range pricings {
-		if pricing.Location.Name == location.Name {
+		if pricing.Location.Name == location {
 			return &pricing, nil
 		}
 	}
 
-	return nil, fmt.Errorf("no load balancer pricing found for location %s", location.Name)
+	return nil, fmt.Errorf("no load balancer pricing found for location %s", location)
 }
@@ -1,11 +1,10 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &loadbalancerTraffic{}
@@ -19,18 +18,19 @@ type loadbalancerTraffic struct {
 	*baseFetcher
 }
 
-func (loadbalancerTraffic loadbalancerTraffic) Run(client *hcloud.Client) error {
-	loadBalancers, _, err := client.LoadBalancer.List(ctx, hcloud.LoadBalancerListOpts{})
+func (loadbalancerTraffic loadbalancerTraffic) Run(ctx context.Context, inventory *Inventory) error {
+	loadBalancers, err := inventory.LoadBalancers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list load balancers for traffic pricing: %w", err)
 	}
 
-	trafficPricePerTB, err := loadbalancerTraffic.pricing.Traffic()
+	trafficPricePerTB, err := loadbalancerTraffic.pricing.Traffic(ctx)
 	if err != nil {
 		log.Printf("Could not get traffic pricing: %v", err)
 		return fmt.Errorf("could not get traffic pricing: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, lb := range loadBalancers {
 		location := lb.Location
 
@@ -41,20 +41,20 @@ func (loadbalancerTraffic loadbalancerTraffic) Run(client *hcloud.Client) error
 		},
 			parseAdditionalLabels(loadbalancerTraffic.additionalLabels, lb.Labels)...,
 		)
+		info := resourceInfo{id: lb.ID, location: location.Name, labels: lb.Labels, created: lb.Created}
 
 		additionalTraffic := int(lb.OutgoingTraffic) - int(lb.IncludedTraffic)
 		if additionalTraffic < 0 {
-			loadbalancerTraffic.hourly.WithLabelValues(labels...).Set(0)
-			loadbalancerTraffic.monthly.WithLabelValues(labels...).Set(0)
+			loadbalancerTraffic.record(result, info, labels, Price{}, Price{})
 			continue // Use continue instead of break to process other load balancers
 		}
 
-		monthlyPrice := math.Ceil(float64(additionalTraffic)/sizeTB) * trafficPricePerTB
+		monthlyPrice := trafficPricePerTB.times(math.Ceil(float64(additionalTraffic) / sizeTB))
 		hourlyPrice := pricingPerHour(monthlyPrice)
 
-		loadbalancerTraffic.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-		loadbalancerTraffic.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+		loadbalancerTraffic.record(result, info, labels, hourlyPrice, monthlyPrice)
 	}
 
+	loadbalancerTraffic.publish(result)
 	return nil
 }
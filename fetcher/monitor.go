@@ -0,0 +1,277 @@
+package fetcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Monitor keeps track of the health of the exporter itself and exposes it as prometheus metrics.
+type Monitor struct {
+	pricing *PriceProvider
+
+	fetchDuration    *prometheus.HistogramVec
+	fetchErrors      *prometheus.CounterVec
+	fetchSkipped     *prometheus.CounterVec
+	lastSuccess      *prometheus.GaugeVec
+	resources        *prometheus.GaugeVec
+	pricingAgeDesc   *prometheus.Desc
+	pricingStaleDesc *prometheus.Desc
+	rateDesc         *prometheus.Desc
+	rateInfoDesc     *prometheus.Desc
+	vatRateDesc      *prometheus.Desc
+
+	statusLock sync.RWMutex
+	statuses   map[string]*fetcherStatus
+}
+
+type fetcherStatus struct {
+	lastSuccess time.Time
+	lastError   error
+	// resources is the number of priced resources of the last successful cycle.
+	resources int
+}
+
+// Readiness describes whether the exporter currently serves up-to-date data.
+type Readiness struct {
+	Ready    bool                     `json:"ready"`
+	Pricing  PricingStatus            `json:"pricing"`
+	Fetchers map[string]FetcherStatus `json:"fetchers"`
+}
+
+// PricingStatus describes the state of the cached pricing information.
+type PricingStatus struct {
+	Cached     bool    `json:"cached"`
+	AgeSeconds float64 `json:"age_seconds,omitempty"`
+	Stale      bool    `json:"stale,omitempty"`
+	LastError  string  `json:"last_error,omitempty"`
+}
+
+// FetcherStatus describes the outcome of the recent data fetching cycles of a single fetcher.
+type FetcherStatus struct {
+	Ready       bool       `json:"ready"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// NewMonitor creates a new monitor that additionally reports the age of the pricing data of the passed provider.
+func NewMonitor(pricing *PriceProvider) *Monitor {
+	monitor := &Monitor{
+		pricing: pricing,
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "fetch_duration_seconds",
+			Help:      "The duration of data fetching cycles per fetcher",
+		}, []string{"fetcher"}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "fetch_errors_total",
+			Help:      "The number of failed data fetching cycles per fetcher",
+		}, []string{"fetcher"}),
+		fetchSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "fetch_skipped_total",
+			Help:      "The number of skipped data fetching cycles per fetcher, whose previous cycle was still running",
+		}, []string{"fetcher"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "The point in time of the last successful data fetching cycle per fetcher",
+		}, []string{"fetcher"}),
+		resources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "resources",
+			Help:      "The number of priced resources per resource type",
+		}, []string{"type"}),
+		statuses: map[string]*fetcherStatus{},
+	}
+	monitor.createPricingDescs()
+	return monitor
+}
+
+// createPricingDescs creates the descriptions of the metrics about the pricing information and the exchange rates.
+func (monitor *Monitor) createPricingDescs() {
+	monitor.pricingAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("hcloud", "pricing_exporter", "pricing_cache_age_seconds"),
+		"The time since the cached pricing information was fetched from the API",
+		nil,
+		nil,
+	)
+	monitor.pricingStaleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("hcloud", "pricing_exporter", "pricing_stale"),
+		"Whether the cached pricing information is served, because the last refresh failed",
+		nil,
+		nil,
+	)
+	monitor.rateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("hcloud", "pricing_exporter", "exchange_rate"),
+		"The exchange rate that converts the prices of the pricing information to the exposed currency",
+		[]string{"from", "to"},
+		nil,
+	)
+	monitor.rateInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("hcloud", "pricing_exporter", "exchange_rate_info"),
+		"The date and the source of the exchange rate that converts the prices to the exposed currency",
+		[]string{"from", "to", "date", "source"},
+		nil,
+	)
+	monitor.vatRateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("hcloud", "pricing", "vat_rate_percent"),
+		"The VAT rate that is applied to net prices, either from the pricing information or overridden",
+		[]string{"source"},
+		nil,
+	)
+}
+
+// Describe implements prometheus.Collector.
+func (monitor *Monitor) Describe(descs chan<- *prometheus.Desc) {
+	monitor.fetchDuration.Describe(descs)
+	monitor.fetchErrors.Describe(descs)
+	monitor.fetchSkipped.Describe(descs)
+	monitor.lastSuccess.Describe(descs)
+	monitor.resources.Describe(descs)
+	descs <- monitor.pricingAgeDesc
+	descs <- monitor.pricingStaleDesc
+	descs <- monitor.rateDesc
+	descs <- monitor.rateInfoDesc
+	descs <- monitor.vatRateDesc
+}
+
+// Collect implements prometheus.Collector.
+func (monitor *Monitor) Collect(metrics chan<- prometheus.Metric) {
+	monitor.fetchDuration.Collect(metrics)
+	monitor.fetchErrors.Collect(metrics)
+	monitor.fetchSkipped.Collect(metrics)
+	monitor.lastSuccess.Collect(metrics)
+	monitor.resources.Collect(metrics)
+
+	if age, ok := monitor.pricing.age(); ok {
+		metrics <- prometheus.MustNewConstMetric(monitor.pricingAgeDesc, prometheus.GaugeValue, age.Seconds())
+
+		stale := 0.0
+		if monitor.pricing.staleness() != nil {
+			stale = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(monitor.pricingStaleDesc, prometheus.GaugeValue, stale)
+	}
+
+	if rate, rates, err := monitor.pricing.exchangeRate(); err == nil && rates != nil {
+		from, to := monitor.pricing.sourceCurrency(), monitor.pricing.Currency
+		metrics <- prometheus.MustNewConstMetric(monitor.rateDesc, prometheus.GaugeValue, rate, from, to)
+		metrics <- prometheus.MustNewConstMetric(
+			monitor.rateInfoDesc, prometheus.GaugeValue, 1, from, to, rates.Date, rates.Source,
+		)
+	}
+
+	if rate, source := monitor.pricing.vatRate(); source != "" {
+		metrics <- prometheus.MustNewConstMetric(monitor.vatRateDesc, prometheus.GaugeValue, rate, source)
+	}
+}
+
+// Readiness reports the exporter as ready, once every fetcher completed a data fetching cycle successfully and none
+// of them is older than the passed maximum age. A maximum age of zero disables the age check.
+func (monitor *Monitor) Readiness(maxAge time.Duration) Readiness {
+	return monitor.ReadinessFor(func(string) time.Duration { return maxAge })
+}
+
+// ReadinessFor works like Readiness, but takes the maximum age per fetcher, e.g. for fetchers that run in different
+// intervals.
+func (monitor *Monitor) ReadinessFor(maxAge func(fetcher string) time.Duration) Readiness {
+	monitor.statusLock.RLock()
+	defer monitor.statusLock.RUnlock()
+
+	readiness := Readiness{
+		Ready:    len(monitor.statuses) > 0,
+		Fetchers: make(map[string]FetcherStatus, len(monitor.statuses)),
+	}
+
+	if age, ok := monitor.pricing.age(); ok {
+		readiness.Pricing = PricingStatus{Cached: true, AgeSeconds: age.Seconds()}
+		if err := monitor.pricing.staleness(); err != nil {
+			readiness.Pricing.Stale = true
+			readiness.Pricing.LastError = err.Error()
+		}
+	}
+
+	for name, status := range monitor.statuses {
+		fetcherReadiness := FetcherStatus{}
+		if !status.lastSuccess.IsZero() {
+			lastSuccess := status.lastSuccess
+			fetcherReadiness.LastSuccess = &lastSuccess
+			fetcherReadiness.Ready = maxAge(name) <= 0 || time.Since(lastSuccess) <= maxAge(name)
+		}
+		if status.lastError != nil {
+			fetcherReadiness.LastError = status.lastError.Error()
+		}
+
+		readiness.Fetchers[name] = fetcherReadiness
+		readiness.Ready = readiness.Ready && fetcherReadiness.Ready
+	}
+
+	return readiness
+}
+
+// Inherit takes over the outcomes of the recent data fetching cycles of the passed monitor, so that a replaced set of
+// fetchers keeps its readiness and its metrics until it completes its own cycles. Only the outcomes of the passed
+// fetchers are taken over, as the ones that no longer run would never become ready again.
+func (monitor *Monitor) Inherit(previous *Monitor, fetchers Fetchers) {
+	previous.statusLock.RLock()
+	defer previous.statusLock.RUnlock()
+	monitor.statusLock.Lock()
+	defer monitor.statusLock.Unlock()
+
+	for _, fetcher := range fetchers {
+		name := fetcher.Name()
+		status, ok := previous.statuses[name]
+		if _, known := monitor.statuses[name]; !ok || known {
+			continue
+		}
+
+		inherited := *status
+		monitor.statuses[name] = &inherited
+		if !status.lastSuccess.IsZero() {
+			monitor.lastSuccess.WithLabelValues(name).Set(float64(status.lastSuccess.UnixNano()) / 1e9)
+			monitor.resources.WithLabelValues(name).Set(float64(status.resources))
+		}
+	}
+}
+
+func (monitor *Monitor) skip(fetcher Fetcher) {
+	monitor.fetchSkipped.WithLabelValues(fetcher.Name()).Inc()
+}
+
+func (monitor *Monitor) observe(fetcher Fetcher, duration time.Duration, err error) {
+	name := fetcher.Name()
+
+	monitor.statusLock.Lock()
+	status, ok := monitor.statuses[name]
+	if !ok {
+		status = &fetcherStatus{}
+		monitor.statuses[name] = status
+	}
+	status.lastError = err
+	if err == nil {
+		status.lastSuccess = time.Now()
+		status.resources = len(fetcher.Snapshot().Resources)
+	}
+	resources := status.resources
+	monitor.statusLock.Unlock()
+
+	monitor.fetchDuration.WithLabelValues(name).Observe(duration.Seconds())
+	// Touch the counters, so that they are exposed with zero before the first error occurs.
+	monitor.fetchSkipped.WithLabelValues(name)
+	errors := monitor.fetchErrors.WithLabelValues(name)
+	if err != nil {
+		errors.Inc()
+		return
+	}
+
+	monitor.lastSuccess.WithLabelValues(name).SetToCurrentTime()
+	monitor.resources.WithLabelValues(name).Set(float64(resources))
+}
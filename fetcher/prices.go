@@ -4,83 +4,371 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
+// backgroundRefreshTimeout is the maximum duration of a refresh of the cached pricing in the background.
+const backgroundRefreshTimeout = time.Minute
+
+// PriceType selects whether net prices, gross prices or both are exposed.
+type PriceType string
+
+const (
+	// PriceTypeNet exposes prices without VAT.
+	PriceTypeNet PriceType = "net"
+	// PriceTypeGross exposes prices including VAT.
+	PriceTypeGross PriceType = "gross"
+	// PriceTypeBoth exposes net and gross prices, distinguished by the price_type label.
+	PriceTypeBoth PriceType = "both"
+)
+
+// PriceTypeLabel is the label that distinguishes net and gross prices, if both are exposed.
+const PriceTypeLabel = "price_type"
+
+// CurrencyLabel is the label that names the currency of the exposed costs.
+const CurrencyLabel = "currency"
+
+// defaultCurrency is the currency of prices that do not state one.
+const defaultCurrency = "EUR"
+
+// The sources of the VAT rate.
+const (
+	vatRatePricing  = "pricing"
+	vatRateOverride = "override"
+)
+
+// PriceTypes returns the names of all price types.
+func PriceTypes() []string {
+	return []string{string(PriceTypeNet), string(PriceTypeGross), string(PriceTypeBoth)}
+}
+
+// exposed returns the price types that are exposed as separate series. Empty price types default to gross.
+func (priceType PriceType) exposed() []PriceType {
+	switch priceType {
+	case PriceTypeNet:
+		return []PriceType{PriceTypeNet}
+	case PriceTypeBoth:
+		return []PriceType{PriceTypeNet, PriceTypeGross}
+	default:
+		return []PriceType{PriceTypeGross}
+	}
+}
+
+// Price is an amount of money, without and including VAT.
+type Price struct {
+	Net   float64 `json:"net"`
+	Gross float64 `json:"gross"`
+}
+
+// of returns the amount of the passed price type, which must not be PriceTypeBoth.
+func (price Price) of(priceType PriceType) float64 {
+	if priceType == PriceTypeNet {
+		return price.Net
+	}
+	return price.Gross
+}
+
+// times multiplies both amounts of the price with the passed factor.
+func (price Price) times(factor float64) Price {
+	return Price{Net: price.Net * factor, Gross: price.Gross * factor}
+}
+
+// plus adds the amounts of the passed price to the amounts of the price.
+func (price Price) plus(other Price) Price {
+	return Price{Net: price.Net + other.Net, Gross: price.Gross + other.Gross}
+}
+
+// atMost caps both amounts of the price at the amounts of the passed price.
+func (price Price) atMost(limit Price) Price {
+	return Price{Net: math.Min(price.Net, limit.Net), Gross: math.Min(price.Gross, limit.Gross)}
+}
+
+// atLeast raises both amounts of the price to at least the amounts of the passed price.
+func (price Price) atLeast(limit Price) Price {
+	return Price{Net: math.Max(price.Net, limit.Net), Gross: math.Max(price.Gross, limit.Gross)}
+}
+
+// atLeastZero replaces negative amounts of the price with zero.
+func (price Price) atLeastZero() Price {
+	return Price{Net: math.Max(price.Net, 0), Gross: math.Max(price.Gross, 0)}
+}
+
 // PriceProvider provides easy access to current HCloud prices.
 type PriceProvider struct {
-	Client      *hcloud.Client
+	Client *hcloud.Client
+	// State persists every successfully fetched pricing, if set.
+	State *StateStore
+	// File replaces the API as the source of pricing information, if set.
+	File *PricingFile
+	// Overrides replaces single prices of the pricing information, if set.
+	Overrides *PricingFile
+	// PriceType selects the prices that the fetchers expose, it defaults to gross prices.
+	PriceType PriceType
+	// Currency is the currency that all prices are converted to with the exchange rates of Rates. Empty keeps the
+	// currency of the pricing information.
+	Currency string
+	// Rates provides the exchange rates for the conversion to Currency.
+	Rates *RatesFile
+	// VATRate replaces the VAT rate of the pricing information in percent, if set, e.g. with zero for reverse charge.
+	VATRate *float64
+	// Adjustments change the prices of the resources they match. If there are any, the list prices are exposed too.
+	Adjustments []Adjustment
+
 	pricing     *hcloud.Pricing
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	syncPending bool
+	refreshErr  error
 	pricingLock sync.RWMutex
+
+	refreshLock sync.Mutex
+	refreshing  atomic.Bool
+
+	// The pricing with all overrides applied is kept, until the pricing or the overrides change.
+	mergeLock       sync.Mutex
+	merged          *hcloud.Pricing
+	mergedBase      *hcloud.Pricing
+	mergedOverrides *hcloud.Pricing
+
+	// now returns the current time, it is replaced by tests.
+	now func() time.Time
 }
 
-// getPricing fetches pricing information if not already cached.
-// It handles locking internally and returns an error if fetching fails.
-func (provider *PriceProvider) getPricing() (*hcloud.Pricing, error) {
-	provider.pricingLock.RLock()
-	// Fast path: check if pricing is already cached
-	if provider.pricing != nil {
-		p := provider.pricing
-		provider.pricingLock.RUnlock()
-		return p, nil
+// getPricing returns the pricing information of the pricing file or the API, with all overrides applied.
+func (provider *PriceProvider) getPricing(ctx context.Context) (*hcloud.Pricing, error) {
+	var pricing *hcloud.Pricing
+	var err error
+	if provider.File != nil {
+		pricing, err = provider.readFile()
+	} else {
+		pricing, err = provider.fetchPricing(ctx)
 	}
-	// Release read lock before acquiring write lock
-	provider.pricingLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.override(pricing), nil
+}
+
+// pinned returns the prices that take precedence over the ones that the API lists along with the resources, or nil if
+// there are none. The pricing information is loaded in any case, as it states the currency and the VAT rate.
+func (provider *PriceProvider) pinned(ctx context.Context) (*hcloud.Pricing, error) {
+	pricing, err := provider.getPricing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case provider.File != nil:
+		return pricing, nil
+	case provider.Overrides != nil:
+		overrides, _ := provider.Overrides.get()
+		return overrides, nil
+	default:
+		return nil, nil
+	}
+}
+
+// readFile returns the pricing of the pricing file. It is cached like fetched pricing, so that its age and failed
+// reads are reported as well.
+func (provider *PriceProvider) readFile() (*hcloud.Pricing, error) {
+	pricing, err := provider.File.get()
 
 	provider.pricingLock.Lock()
 	defer provider.pricingLock.Unlock()
-	// Double-check after acquiring write lock, another goroutine might have fetched it.
-	if provider.pricing != nil {
-		return provider.pricing, nil
+
+	provider.refreshErr = err
+	if pricing == nil {
+		return nil, fmt.Errorf("failed to read pricing from file: %w", err)
+	}
+	if pricing != provider.pricing {
+		provider.pricing = pricing
+		provider.fetchedAt = provider.clock()
+	}
+	return pricing, nil
+}
+
+// override applies the overrides to the passed pricing.
+func (provider *PriceProvider) override(pricing *hcloud.Pricing) *hcloud.Pricing {
+	if provider.Overrides == nil {
+		return pricing
+	}
+	overrides, _ := provider.Overrides.get()
+
+	provider.mergeLock.Lock()
+	defer provider.mergeLock.Unlock()
+
+	if provider.merged == nil || provider.mergedBase != pricing || provider.mergedOverrides != overrides {
+		provider.merged = mergePricing(pricing, overrides)
+		provider.mergedBase, provider.mergedOverrides = pricing, overrides
+	}
+	return provider.merged
+}
+
+// fetchPricing returns the cached pricing information. Only if nothing is cached yet, it is fetched right away. Due
+// refreshes happen in the background, while the cached pricing stays in use.
+func (provider *PriceProvider) fetchPricing(ctx context.Context) (*hcloud.Pricing, error) {
+	provider.pricingLock.RLock()
+	pricing, due := provider.pricing, provider.refreshDue()
+	provider.pricingLock.RUnlock()
+
+	switch {
+	case pricing == nil:
+		// There is nothing to serve in the meantime, so the first fetch blocks.
+		return provider.refresh(ctx)
+	case due && provider.refreshing.CompareAndSwap(false, true):
+		go func() {
+			defer provider.refreshing.Store(false)
+
+			// The refresh must outlive the fetcher that triggered it.
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundRefreshTimeout)
+			defer cancel()
+			_, _ = provider.refresh(ctx)
+		}()
+	}
+
+	return pricing, nil
+}
+
+// refresh fetches pricing information from the API, unless another refresh made it unnecessary in the meantime. If the
+// fetch fails, the cached pricing is kept and returned.
+func (provider *PriceProvider) refresh(ctx context.Context) (*hcloud.Pricing, error) {
+	provider.refreshLock.Lock()
+	defer provider.refreshLock.Unlock()
+
+	provider.pricingLock.Lock()
+	if provider.pricing != nil && !provider.refreshDue() {
+		pricing := provider.pricing
+		provider.pricingLock.Unlock()
+		return pricing, nil
 	}
+	// Every due refresh is attempted once, failures are retried with the next Sync or in the next month.
+	provider.attemptedAt = provider.clock()
+	provider.syncPending = false
+	provider.pricingLock.Unlock()
+
+	log.Println("Pricing cache empty or due for a refresh, fetching from HCloud API...")
+	pricing, _, err := provider.Client.Pricing.Get(ctx)
+
+	provider.pricingLock.Lock()
+	defer provider.pricingLock.Unlock()
 
-	// Fetch pricing
-	log.Println("Pricing cache empty or invalidated, fetching from HCloud API...")
-	pricing, _, err := provider.Client.Pricing.Get(context.Background())
 	if err != nil {
+		provider.refreshErr = err
+		if provider.pricing != nil {
+			log.Printf("Error refreshing pricing from HCloud API, serving stale pricing from %s (%s old): %v",
+				provider.fetchedAt.Format(time.RFC3339), provider.clock().Sub(provider.fetchedAt).Round(time.Second), err)
+			return provider.pricing, nil
+		}
+
 		log.Printf("Error fetching pricing from HCloud API: %v", err)
 		return nil, fmt.Errorf("failed to fetch pricing from API: %w", err)
 	}
 
 	log.Println("Successfully fetched pricing information from API.")
 	provider.pricing = &pricing
+	provider.fetchedAt = provider.clock()
+	provider.refreshErr = nil
+	if err := saveState(provider.State, "pricing", pricing); err != nil {
+		log.Printf("Failed to persist pricing: %v", err)
+	}
 	return provider.pricing, nil
 }
 
+// Restore loads the pricing that was persisted to the state store, unless pricing is cached already. It is marked for a
+// refresh, which happens in the background on its first use. The returned flag is false, if nothing was persisted.
+func (provider *PriceProvider) Restore() (bool, error) {
+	pricing, savedAt, ok, err := loadState[hcloud.Pricing](provider.State, "pricing")
+	if err != nil || !ok {
+		return false, err
+	}
+
+	provider.pricingLock.Lock()
+	defer provider.pricingLock.Unlock()
+
+	if provider.pricing != nil {
+		return true, nil
+	}
+	provider.pricing = &pricing
+	provider.fetchedAt = savedAt
+	provider.syncPending = true
+	return true, nil
+}
+
+// refreshDue reports whether the cached pricing should be refreshed, because Sync was called or because it was fetched
+// in a previous calendar month, as prices usually change on the first of a month. The caller must hold the lock.
+func (provider *PriceProvider) refreshDue() bool {
+	now := provider.clock()
+	return provider.syncPending ||
+		provider.attemptedAt.Year() != now.Year() || provider.attemptedAt.Month() != now.Month()
+}
+
+func (provider *PriceProvider) clock() time.Time {
+	if provider.now != nil {
+		return provider.now()
+	}
+	return time.Now()
+}
+
+// age returns how long ago the cached pricing information was fetched, or false if nothing is cached.
+func (provider *PriceProvider) age() (time.Duration, bool) {
+	provider.pricingLock.RLock()
+	defer provider.pricingLock.RUnlock()
+
+	if provider.pricing == nil {
+		return 0, false
+	}
+	return provider.clock().Sub(provider.fetchedAt), true
+}
+
+// staleness returns the error of the last refresh, if the cached pricing is served because the refresh failed.
+func (provider *PriceProvider) staleness() error {
+	provider.pricingLock.RLock()
+	defer provider.pricingLock.RUnlock()
+
+	if provider.pricing == nil {
+		return nil
+	}
+	return provider.refreshErr
+}
+
 // FloatingIP returns the current price for a floating IP per month.
-func (provider *PriceProvider) FloatingIP(ipType hcloud.FloatingIPType, location string) (float64, error) {
-	pricingInfo, err := provider.getPricing()
+func (provider *PriceProvider) FloatingIP(ctx context.Context, ipType hcloud.FloatingIPType, location string) (Price, error) {
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get pricing information: %w", err)
+		return Price{}, fmt.Errorf("failed to get pricing information: %w", err)
 	}
 
 	for _, byType := range pricingInfo.FloatingIPs {
 		if byType.Type == ipType {
 			for _, pricing := range byType.Pricings {
 				if pricing.Location.Name == location {
-					return parsePrice(pricing.Monthly.Gross), nil
+					return provider.price(pricing.Monthly)
 				}
 			}
 		}
 	}
 
 	// Fallback logic removed, assume API provides specific pricing or it's an error.
-	return 0, fmt.Errorf("no floating IP pricing found for type %s in location %s", ipType, location)
+	return Price{}, fmt.Errorf("no floating IP pricing found for type %s in location %s", ipType, location)
 }
 
 // PrimaryIP returns the current price for a primary IP per hour and month.
-func (provider *PriceProvider) PrimaryIP(ipType hcloud.PrimaryIPType, location string) (hourly, monthly float64, err error) {
+func (provider *PriceProvider) PrimaryIP(ctx context.Context, ipType hcloud.PrimaryIPType, location string) (hourly, monthly Price, err error) {
 	// v6 pricing is not defined by the API
 	if string(ipType) == "ipv6" {
-		return 0, 0, nil
+		return Price{}, Price{}, nil
 	}
 
-	pricingInfo, err := provider.getPricing()
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get pricing information: %w", err)
+		return Price{}, Price{}, fmt.Errorf("failed to get pricing information: %w", err)
 	}
 
 	for _, byType := range pricingInfo.PrimaryIPs {
@@ -88,36 +376,103 @@ func (provider *PriceProvider) PrimaryIP(ipType hcloud.PrimaryIPType, location s
 			for _, pricing := range byType.Pricings {
 				// API uses Location.Name for Primary IPs pricing location identifier
 				if pricing.Location == location {
-					return parsePrice(pricing.Hourly.Gross), parsePrice(pricing.Monthly.Gross), nil
+					return provider.prices(
+						hcloud.Price{Net: pricing.Hourly.Net, Gross: pricing.Hourly.Gross},
+						hcloud.Price{Net: pricing.Monthly.Net, Gross: pricing.Monthly.Gross},
+					)
+				}
+			}
+		}
+	}
+
+	return Price{}, Price{}, fmt.Errorf("no primary IP pricing found for type %s in location %s", ipType, location)
+}
+
+// Server returns the current price of a server type per hour and month in the passed location. Prices of the pricing
+// file and the overrides take precedence over the ones that the API lists along with the server type.
+func (provider *PriceProvider) Server(
+	ctx context.Context, serverType *hcloud.ServerType, location string,
+) (hourly, monthly Price, err error) {
+	pinned, err := provider.pinned(ctx)
+	if err != nil {
+		return Price{}, Price{}, fmt.Errorf("failed to get pricing information: %w", err)
+	}
+
+	pricing, err := findServerPricing(location, serverType.Pricings)
+	if pinned != nil {
+		for _, byType := range pinned.ServerTypes {
+			if byType.ServerType.Name != serverType.Name {
+				continue
+			}
+			if pinnedPricing, pinnedErr := findServerPricing(location, byType.Pricings); pinnedErr == nil {
+				if err == nil {
+					merged := mergeServerPricing(*pricing, *pinnedPricing)
+					pinnedPricing = &merged
+				}
+				pricing, err = pinnedPricing, nil
+			}
+		}
+	}
+	if err != nil {
+		return Price{}, Price{}, err
+	}
+
+	return provider.prices(pricing.Hourly, pricing.Monthly)
+}
+
+// LoadBalancer returns the current price of a load balancer type per hour and month in the passed location. Prices of
+// the pricing file and the overrides take precedence over the ones that the API lists along with the type.
+func (provider *PriceProvider) LoadBalancer(
+	ctx context.Context, loadBalancerType *hcloud.LoadBalancerType, location string,
+) (hourly, monthly Price, err error) {
+	pinned, err := provider.pinned(ctx)
+	if err != nil {
+		return Price{}, Price{}, fmt.Errorf("failed to get pricing information: %w", err)
+	}
+
+	pricing, err := findLBPricing(location, loadBalancerType.Pricings)
+	if pinned != nil {
+		for _, byType := range pinned.LoadBalancerTypes {
+			if byType.LoadBalancerType.Name != loadBalancerType.Name {
+				continue
+			}
+			if pinnedPricing, pinnedErr := findLBPricing(location, byType.Pricings); pinnedErr == nil {
+				if err == nil {
+					merged := mergeLBPricing(*pricing, *pinnedPricing)
+					pinnedPricing = &merged
 				}
+				pricing, err = pinnedPricing, nil
 			}
 		}
 	}
+	if err != nil {
+		return Price{}, Price{}, err
+	}
 
-	return 0, 0, fmt.Errorf("no primary IP pricing found for type %s in location %s", ipType, location)
+	return provider.prices(pricing.Hourly, pricing.Monthly)
 }
 
 // Image returns the current price for an image per GB per month.
-func (provider *PriceProvider) Image() (float64, error) {
-	pricingInfo, err := provider.getPricing()
+func (provider *PriceProvider) Image(ctx context.Context) (Price, error) {
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get pricing information: %w", err)
+		return Price{}, fmt.Errorf("failed to get pricing information: %w", err)
 	}
-	return parsePrice(pricingInfo.Image.PerGBMonth.Gross), nil
+	return provider.price(pricingInfo.Image.PerGBMonth)
 }
 
 // Traffic returns the current price for a TB of extra traffic per month.
-func (provider *PriceProvider) Traffic() (float64, error) {
-	pricingInfo, err := provider.getPricing()
+func (provider *PriceProvider) Traffic(ctx context.Context) (Price, error) {
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get pricing information: %w", err)
+		return Price{}, fmt.Errorf("failed to get pricing information: %w", err)
 	}
-	return parsePrice(pricingInfo.Traffic.PerTB.Gross), nil
+	return provider.price(pricingInfo.Traffic.PerTB)
 }
 
 // ServerBackup returns the percentage of base price increase for server backups per month.
-func (provider *PriceProvider) ServerBackup() (float64, error) {
-	pricingInfo, err := provider.getPricing()
+func (provider *PriceProvider) ServerBackup(ctx context.Context) (float64, error) {
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get pricing information: %w", err)
 	}
@@ -125,21 +480,130 @@ func (provider *PriceProvider) ServerBackup() (float64, error) {
 }
 
 // Volume returns the current price for a volume per GB per month.
-func (provider *PriceProvider) Volume() (float64, error) {
-	pricingInfo, err := provider.getPricing()
+func (provider *PriceProvider) Volume(ctx context.Context) (Price, error) {
+	pricingInfo, err := provider.getPricing(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get pricing information: %w", err)
+		return Price{}, fmt.Errorf("failed to get pricing information: %w", err)
+	}
+	return provider.price(pricingInfo.Volume.PerGBMonthly)
+}
+
+// currency returns the currency of the prices that the provider returns.
+func (provider *PriceProvider) currency() string {
+	if provider.Currency != "" {
+		return provider.Currency
+	}
+	return provider.sourceCurrency()
+}
+
+// sourceCurrency returns the currency of the cached pricing information. Prices that do not state a currency are in
+// euros, which is the currency that HCloud bills in.
+func (provider *PriceProvider) sourceCurrency() string {
+	provider.pricingLock.RLock()
+	defer provider.pricingLock.RUnlock()
+
+	if provider.pricing != nil {
+		for _, price := range pricesOf(provider.pricing) {
+			if price.Currency != "" {
+				return price.Currency
+			}
+		}
+	}
+	return defaultCurrency
+}
+
+// exchangeRate returns the factor that converts prices in the source currency to the target currency, along with the
+// exchange rates it was taken from. The rates are nil, if no conversion takes place.
+func (provider *PriceProvider) exchangeRate() (float64, *ExchangeRates, error) {
+	from := provider.sourceCurrency()
+	if provider.Currency == "" || provider.Currency == from {
+		return 1, nil, nil
+	}
+	if provider.Rates == nil {
+		return 0, nil, fmt.Errorf("no exchange rates to convert %s to %s", from, provider.Currency)
+	}
+
+	rates, err := provider.Rates.get()
+	if rates == nil {
+		return 0, nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+	rate, err := rates.rate(from, provider.Currency)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to convert %s to %s: %w", from, provider.Currency, err)
+	}
+	return rate, rates, nil
+}
+
+// price is the tax-aware layer that every price passes through. Unless the VAT rate is overridden, the gross amount of
+// the pricing information is kept; otherwise, or if there is none, it is derived from the net amount. The result is
+// converted to the target currency.
+func (provider *PriceProvider) price(raw hcloud.Price) (Price, error) {
+	price := Price{Net: parsePrice(raw.Net), Gross: parsePrice(raw.Gross)}
+	if rate, source := provider.vatRate(); source == vatRateOverride || raw.Gross == "" {
+		price.Gross = price.Net * (1 + rate/100)
+	}
+	return provider.convert(price)
+}
+
+// prices passes an hourly and a monthly price through the tax-aware layer.
+func (provider *PriceProvider) prices(hourly, monthly hcloud.Price) (Price, Price, error) {
+	hourlyPrice, err := provider.price(hourly)
+	if err != nil {
+		return Price{}, Price{}, err
+	}
+	monthlyPrice, err := provider.price(monthly)
+	if err != nil {
+		return Price{}, Price{}, err
+	}
+	return hourlyPrice, monthlyPrice, nil
+}
+
+// vatRate returns the VAT rate in percent and where it comes from. Without an override, it is the rate of the cached
+// pricing information, or zero with an empty source if that does not state one.
+func (provider *PriceProvider) vatRate() (rate float64, source string) {
+	if provider.VATRate != nil {
+		return *provider.VATRate, vatRateOverride
+	}
+
+	provider.pricingLock.RLock()
+	defer provider.pricingLock.RUnlock()
+
+	if provider.pricing != nil {
+		for _, price := range pricesOf(provider.pricing) {
+			if price.VATRate != "" {
+				return parsePrice(price.VATRate), vatRatePricing
+			}
+		}
 	}
-	return parsePrice(pricingInfo.Volume.PerGBMonthly.Gross), nil
+	return 0, ""
 }
 
-// Sync forces the provider to re-fetch prices on the next access.
+// convert converts the passed price from the source currency to the target currency.
+func (provider *PriceProvider) convert(price Price) (Price, error) {
+	rate, _, err := provider.exchangeRate()
+	if err != nil {
+		return Price{}, err
+	}
+	return price.times(rate), nil
+}
+
+// Sync makes the provider re-fetch prices on the next access. The cached prices are kept, until the new ones arrived.
 func (provider *PriceProvider) Sync() {
 	provider.pricingLock.Lock()         // Acquire Write lock
 	defer provider.pricingLock.Unlock() // Release Write lock
 
-	log.Println("Invalidating pricing cache.")
-	provider.pricing = nil // Clear the cache
+	log.Println("Marking pricing cache for a refresh.")
+	provider.syncPending = true
+}
+
+// pricesOf returns the prices of the pricing information that state its currency and VAT rate.
+func pricesOf(pricing *hcloud.Pricing) []hcloud.Price {
+	return []hcloud.Price{
+		pricing.Image.PerGBMonth,
+		pricing.Volume.PerGBMonthly,
+		pricing.FloatingIP.Monthly,
+		pricing.Traffic.PerTB,
+	}
 }
 
 func parsePrice(rawPrice string) float64 {
@@ -0,0 +1,178 @@
+package fetcher_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For the price provider", func() {
+	var (
+		api      *fakeAPI
+		client   *hcloud.Client
+		clock    *fakeClock
+		pricing  *fetcher.PriceProvider
+		fetchers fetcher.Fetchers
+	)
+
+	BeforeEach(func() {
+		api, client = newFakeAPI(map[string]int{"volumes": 1})
+		clock = &fakeClock{now: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)}
+		pricing = &fetcher.PriceProvider{Client: client}
+		fetcher.SetClock(pricing, clock.Now)
+		fetchers = fetcher.Fetchers{fetcher.NewVolume(pricing)}
+	})
+
+	It("should fetch the pricing once", func(ctx context.Context) {
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(api.Requests("pricing")).To(Equal(1))
+	})
+
+	It("should keep serving the previous pricing, if a refresh fails", func(ctx context.Context) {
+		monitor := fetcher.NewMonitor(pricing)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		monthly := fetchers[0].Snapshot().Find("volumes-1", "fsn1", "10").Monthly
+		Expect(testutil.CollectAndCompare(monitor, pricingStaleMetric(0), "hcloud_pricing_exporter_pricing_stale")).To(Succeed())
+
+		pricing.Sync()
+		api.Fail("pricing", true)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Eventually(func() int { return api.Requests("pricing") }).Should(Equal(2))
+		Expect(fetchers[0].Snapshot().Find("volumes-1", "fsn1", "10").Monthly).To(Equal(monthly))
+		Eventually(func() error {
+			return testutil.CollectAndCompare(monitor, pricingStaleMetric(1), "hcloud_pricing_exporter_pricing_stale")
+		}).Should(Succeed())
+		Expect(monitor.Readiness(0).Pricing.LastError).To(ContainSubstring("fake outage"))
+
+		// The failed refresh is not repeated until the next sync.
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Consistently(func() int { return api.Requests("pricing") }, "50ms").Should(Equal(2))
+
+		pricing.Sync()
+		api.Fail("pricing", false)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Eventually(func() error {
+			return testutil.CollectAndCompare(monitor, pricingStaleMetric(0), "hcloud_pricing_exporter_pricing_stale")
+		}).Should(Succeed())
+	})
+
+	It("should not wait for a refresh of the cached pricing", func(ctx context.Context) {
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		release := api.Block("pricing")
+		defer release()
+		pricing.Sync()
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Eventually(func() int { return api.Requests("pricing") }).Should(Equal(2))
+	})
+
+	It("should fail, if no pricing was ever fetched", func(ctx context.Context) {
+		api.Fail("pricing", true)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(MatchError(ContainSubstring("pricing")))
+	})
+
+	It("should refresh the pricing in a new month", func(ctx context.Context) {
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Expect(api.Requests("pricing")).To(Equal(1))
+
+		clock.Advance(2 * time.Hour)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Eventually(func() int { return api.Requests("pricing") }).Should(Equal(2))
+
+		clock.Advance(24 * time.Hour)
+		Expect(fetchers.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+		Consistently(func() int { return api.Requests("pricing") }, "50ms").Should(Equal(2))
+	})
+})
+
+var _ = Describe("For the VAT rate", func() {
+	var client *hcloud.Client
+
+	BeforeEach(func() {
+		_, client = newFakeAPI(map[string]int{"servers": 1})
+	})
+
+	It("should keep the gross prices and expose the rate of the pricing", func(ctx context.Context) {
+		pricing := &fetcher.PriceProvider{Client: client}
+		Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236, 1e-6))
+		Expect(testutil.CollectAndCompare(fetcher.NewMonitor(pricing), vatRateMetric("pricing", 19),
+			"hcloud_pricing_vat_rate_percent")).To(Succeed())
+	})
+
+	It("should compute the gross prices with an overridden rate", func(ctx context.Context) {
+		reverseCharge := 0.0
+		pricing := &fetcher.PriceProvider{Client: client, VATRate: &reverseCharge}
+
+		price, err := pricing.Volume(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(price.Gross).To(Equal(price.Net))
+		Expect(testutil.CollectAndCompare(fetcher.NewMonitor(pricing), vatRateMetric("override", 0),
+			"hcloud_pricing_vat_rate_percent")).To(Succeed())
+	})
+
+	It("should apply an overridden rate to the servers as well", func(ctx context.Context) {
+		rate := 10.0
+		server := fetcher.NewServer(&fetcher.PriceProvider{Client: client, VATRate: &rate})
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		monthly := server.Snapshot().Find("servers-1", "fsn1", "cx22").Monthly
+		Expect(monthly.Gross).To(BeNumerically("~", monthly.Net*1.1, 1e-6))
+	})
+
+	It("should expose the rate of the pricing, if only servers are fetched", func(ctx context.Context) {
+		pricing := &fetcher.PriceProvider{Client: client}
+		server := fetcher.NewServer(pricing)
+		Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(server.Snapshot().Currency).To(Equal("EUR"))
+		Expect(testutil.CollectAndCompare(fetcher.NewMonitor(pricing), vatRateMetric("pricing", 19),
+			"hcloud_pricing_vat_rate_percent")).To(Succeed())
+	})
+})
+
+func vatRateMetric(source string, value float64) io.Reader {
+	return strings.NewReader(fmt.Sprintf(`
+# HELP hcloud_pricing_vat_rate_percent The VAT rate that is applied to net prices, either from the pricing information or overridden
+# TYPE hcloud_pricing_vat_rate_percent gauge
+hcloud_pricing_vat_rate_percent{source=%q} %g
+`, source, value))
+}
+
+// fakeClock is a clock that only moves forward when told to.
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(duration time.Duration) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	clock.now = clock.now.Add(duration)
+}
+
+func pricingStaleMetric(value int) io.Reader {
+	return strings.NewReader(fmt.Sprintf(`
+# HELP hcloud_pricing_exporter_pricing_stale Whether the cached pricing information is served, because the last refresh failed
+# TYPE hcloud_pricing_exporter_pricing_stale gauge
+hcloud_pricing_exporter_pricing_stale %d
+`, value))
+}
@@ -0,0 +1,160 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/hetznercloud/hcloud-go/hcloud/schema"
+)
+
+// PricingFile reads pricing information from a local file in the shape of the response of the /pricing endpoint. The
+// file is read again, whenever it changes.
+type PricingFile struct {
+	file *watchedFile[*hcloud.Pricing]
+}
+
+// NewPricingFile creates a pricing file and reads it right away, so that invalid files are reported early.
+func NewPricingFile(path string) (*PricingFile, error) {
+	file := &PricingFile{file: newWatchedFile(path, "pricing file", readPricingFile)}
+	if _, err := file.get(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// get returns the pricing of the file and reads it again, if it changed since the last read. If the changed file cannot
+// be read, the previous pricing is returned along with the error.
+func (file *PricingFile) get() (*hcloud.Pricing, error) {
+	return file.file.get()
+}
+
+func readPricingFile(path string) (*hcloud.Pricing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+
+	var response schema.PricingGetResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode pricing file %s: %w", path, err)
+	}
+
+	pricing := hcloud.PricingFromSchema(response.Pricing)
+	return &pricing, nil
+}
+
+// mergePricing returns a copy of the base pricing, in which every price that is set by the overrides is replaced.
+// Prices of types and locations that are unknown to the base pricing are added.
+func mergePricing(base, overrides *hcloud.Pricing) *hcloud.Pricing {
+	merged := *base
+
+	merged.Image.PerGBMonth = mergePrice(base.Image.PerGBMonth, overrides.Image.PerGBMonth)
+	merged.FloatingIP.Monthly = mergePrice(base.FloatingIP.Monthly, overrides.FloatingIP.Monthly)
+	merged.Traffic.PerTB = mergePrice(base.Traffic.PerTB, overrides.Traffic.PerTB)
+	merged.Volume.PerGBMonthly = mergePrice(base.Volume.PerGBMonthly, overrides.Volume.PerGBMonthly)
+	if overrides.ServerBackup.Percentage != "" {
+		merged.ServerBackup.Percentage = overrides.ServerBackup.Percentage
+	}
+
+	merged.FloatingIPs = mergeByKey(base.FloatingIPs, overrides.FloatingIPs,
+		func(pricing hcloud.FloatingIPTypePricing) string { return string(pricing.Type) },
+		func(base, override hcloud.FloatingIPTypePricing) hcloud.FloatingIPTypePricing {
+			base.Pricings = mergeByKey(base.Pricings, override.Pricings,
+				func(pricing hcloud.FloatingIPTypeLocationPricing) string { return pricing.Location.Name },
+				func(base, override hcloud.FloatingIPTypeLocationPricing) hcloud.FloatingIPTypeLocationPricing {
+					base.Monthly = mergePrice(base.Monthly, override.Monthly)
+					return base
+				})
+			return base
+		})
+
+	merged.PrimaryIPs = mergeByKey(base.PrimaryIPs, overrides.PrimaryIPs,
+		func(pricing hcloud.PrimaryIPPricing) string { return pricing.Type },
+		func(base, override hcloud.PrimaryIPPricing) hcloud.PrimaryIPPricing {
+			base.Pricings = mergeByKey(base.Pricings, override.Pricings,
+				func(pricing hcloud.PrimaryIPTypePricing) string { return pricing.Location },
+				func(base, override hcloud.PrimaryIPTypePricing) hcloud.PrimaryIPTypePricing {
+					base.Hourly = mergePrimaryIPPrice(base.Hourly, override.Hourly)
+					base.Monthly = mergePrimaryIPPrice(base.Monthly, override.Monthly)
+					return base
+				})
+			return base
+		})
+
+	merged.ServerTypes = mergeByKey(base.ServerTypes, overrides.ServerTypes,
+		func(pricing hcloud.ServerTypePricing) string { return pricing.ServerType.Name },
+		func(base, override hcloud.ServerTypePricing) hcloud.ServerTypePricing {
+			base.Pricings = mergeByKey(base.Pricings, override.Pricings,
+				func(pricing hcloud.ServerTypeLocationPricing) string { return pricing.Location.Name },
+				mergeServerPricing)
+			return base
+		})
+
+	merged.LoadBalancerTypes = mergeByKey(base.LoadBalancerTypes, overrides.LoadBalancerTypes,
+		func(pricing hcloud.LoadBalancerTypePricing) string { return pricing.LoadBalancerType.Name },
+		func(base, override hcloud.LoadBalancerTypePricing) hcloud.LoadBalancerTypePricing {
+			base.Pricings = mergeByKey(base.Pricings, override.Pricings,
+				func(pricing hcloud.LoadBalancerTypeLocationPricing) string { return pricing.Location.Name },
+				mergeLBPricing)
+			return base
+		})
+
+	return &merged
+}
+
+// mergeByKey merges the overrides into the base items with the same key and appends the ones without a counterpart.
+func mergeByKey[T any](base, overrides []T, key func(T) string, merge func(base, override T) T) []T {
+	merged := slices.Clone(base)
+	for _, override := range overrides {
+		index := slices.IndexFunc(merged, func(item T) bool { return key(item) == key(override) })
+		if index < 0 {
+			merged = append(merged, override)
+			continue
+		}
+		merged[index] = merge(merged[index], override)
+	}
+	return merged
+}
+
+func mergeServerPricing(base, override hcloud.ServerTypeLocationPricing) hcloud.ServerTypeLocationPricing {
+	base.Hourly = mergePrice(base.Hourly, override.Hourly)
+	base.Monthly = mergePrice(base.Monthly, override.Monthly)
+	base.PerTBTraffic = mergePrice(base.PerTBTraffic, override.PerTBTraffic)
+	return base
+}
+
+func mergeLBPricing(base, override hcloud.LoadBalancerTypeLocationPricing) hcloud.LoadBalancerTypeLocationPricing {
+	base.Hourly = mergePrice(base.Hourly, override.Hourly)
+	base.Monthly = mergePrice(base.Monthly, override.Monthly)
+	base.PerTBTraffic = mergePrice(base.PerTBTraffic, override.PerTBTraffic)
+	return base
+}
+
+func mergePrice(base, override hcloud.Price) hcloud.Price {
+	if override.Net != "" {
+		base.Net = override.Net
+	}
+	if override.Gross != "" {
+		base.Gross = override.Gross
+	}
+	if override.Currency != "" {
+		base.Currency = override.Currency
+	}
+	if override.VATRate != "" {
+		base.VATRate = override.VATRate
+	}
+	return base
+}
+
+func mergePrimaryIPPrice(base, override hcloud.PrimaryIPPrice) hcloud.PrimaryIPPrice {
+	if override.Net != "" {
+		base.Net = override.Net
+	}
+	if override.Gross != "" {
+		base.Gross = override.Gross
+	}
+	return base
+}
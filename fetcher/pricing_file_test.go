@@ -0,0 +1,129 @@
+package fetcher_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const volumeOverride = `{"pricing": {"volume": {"price_per_gb_month": {"net": "0.1", "gross": "0.2"}}}}`
+
+func writePricingFile(content string) string {
+	path := filepath.Join(GinkgoT().TempDir(), "pricing.json")
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	return path
+}
+
+// gross returns the gross amount of a successfully looked up price.
+func gross(price fetcher.Price, err error) float64 {
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return price.Gross
+}
+
+// rewritePricingFile replaces the content of the file and moves its modification time, so that the change is noticed
+// even within the resolution of the file system.
+func rewritePricingFile(path, content string) {
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	modTime := time.Now().Add(time.Minute)
+	Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+}
+
+var _ = Describe("For a pricing file", func() {
+	var (
+		api    *fakeAPI
+		client *hcloud.Client
+	)
+
+	BeforeEach(func() {
+		api, client = newFakeAPI(map[string]int{"servers": 1})
+	})
+
+	It("should refuse an invalid file", func() {
+		_, err := fetcher.NewPricingFile(writePricingFile(`{"pricing": [`))
+		Expect(err).To(MatchError(ContainSubstring("failed to decode pricing file")))
+
+		_, err = fetcher.NewPricingFile(filepath.Join(GinkgoT().TempDir(), "missing.json"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	When("it replaces the API", func() {
+		var (
+			path    string
+			pricing *fetcher.PriceProvider
+		)
+
+		BeforeEach(func() {
+			raw, err := os.ReadFile("testdata/pricing.json")
+			Expect(err).NotTo(HaveOccurred())
+			path = writePricingFile(string(raw))
+
+			file, err := fetcher.NewPricingFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			pricing = &fetcher.PriceProvider{Client: client, File: file}
+			api.Fail("pricing", true)
+		})
+
+		It("should never call the API", func(ctx context.Context) {
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236, 1e-6))
+			Expect(api.Requests("pricing")).To(BeZero())
+		})
+
+		It("should pick up changes of the file", func(ctx context.Context) {
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236, 1e-6))
+
+			rewritePricingFile(path, volumeOverride)
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.2, 1e-6))
+		})
+
+		It("should keep the previous pricing, if the changed file is invalid", func(ctx context.Context) {
+			monitor := fetcher.NewMonitor(pricing)
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236, 1e-6))
+
+			rewritePricingFile(path, `{"pricing": [`)
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236, 1e-6))
+			Expect(testutil.CollectAndCompare(monitor, pricingStaleMetric(1), "hcloud_pricing_exporter_pricing_stale")).
+				To(Succeed())
+		})
+
+		It("should use its prices for servers as well", func(ctx context.Context) {
+			rewritePricingFile(path, `{"pricing": {"server_types": [{"name": "cx22", "prices": [
+				{"location": "fsn1", "price_monthly": {"net": "8.0", "gross": "9.5"}}
+			]}]}}`)
+
+			server := fetcher.NewServer(pricing)
+			Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(server.Snapshot().Find("servers-1", "fsn1", "cx22").Monthly.Gross).
+				To(BeNumerically("~", 9.5, 1e-6))
+		})
+	})
+
+	When("it overrides single prices", func() {
+		var pricing *fetcher.PriceProvider
+
+		BeforeEach(func() {
+			overrides, err := fetcher.NewPricingFile(writePricingFile(volumeOverride + "\n"))
+			Expect(err).NotTo(HaveOccurred())
+			pricing = &fetcher.PriceProvider{Client: client, Overrides: overrides}
+		})
+
+		It("should replace only the overridden prices of the API", func(ctx context.Context) {
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.2, 1e-6))
+			Expect(gross(pricing.Image(ctx))).To(BeNumerically("~", 0.014161, 1e-6))
+			Expect(api.Requests("pricing")).To(Equal(1))
+		})
+
+		It("should keep the prices that the API lists along with servers", func(ctx context.Context) {
+			server := fetcher.NewServer(pricing)
+			Expect(fetcher.Fetchers{server}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+			Expect(server.Snapshot().Find("servers-1", "fsn1", "cx22").Monthly.Gross).
+				To(BeNumerically("~", 4.5101, 1e-5))
+		})
+	})
+})
@@ -1,10 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &floatingIP{}
@@ -18,17 +17,18 @@ type primaryIP struct {
 	*baseFetcher
 }
 
-func (primaryIP primaryIP) Run(client *hcloud.Client) error {
-	primaryIPs, _, err := client.PrimaryIP.List(ctx, hcloud.PrimaryIPListOpts{})
+func (primaryIP primaryIP) Run(ctx context.Context, inventory *Inventory) error {
+	primaryIPs, err := inventory.PrimaryIPs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list primary IPs: %w", err) // Wrap error
 	}
 
+	result := newSnapshot()
 	for _, p := range primaryIPs {
 		datacenter := p.Datacenter
 
 		// Get pricing, handle potential error
-		hourlyPrice, monthlyPrice, err := primaryIP.pricing.PrimaryIP(p.Type, datacenter.Location.Name)
+		hourlyPrice, monthlyPrice, err := primaryIP.pricing.PrimaryIP(ctx, p.Type, datacenter.Location.Name)
 		if err != nil {
 			// Log the error and return it to stop this fetcher's run and report the issue.
 			log.Printf("Could not get primary IP pricing for %s (%s, %s): %v", p.Name, p.Type, datacenter.Location.Name, err)
@@ -43,9 +43,10 @@ func (primaryIP primaryIP) Run(client *hcloud.Client) error {
 			parseAdditionalLabels(primaryIP.additionalLabels, p.Labels)...,
 		)
 
-		primaryIP.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-		primaryIP.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+		info := resourceInfo{id: p.ID, location: datacenter.Location.Name, labels: p.Labels, created: p.Created}
+		primaryIP.record(result, info, labels, hourlyPrice, monthlyPrice)
 	}
 
+	primaryIP.publish(result)
 	return nil
 }
@@ -0,0 +1,164 @@
+package fetcher
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultMaxRetries  = 3
+	defaultBaseBackoff = 500 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+)
+
+// RateLimiter is a http.RoundTripper that keeps track of the request budget of the HCloud API and retries requests
+// that were throttled or failed on the server side with a jittered, exponential backoff.
+type RateLimiter struct {
+	// Reserve is the number of remaining requests below which the budget is considered to be running low.
+	Reserve int
+	// MaxRetries is the number of times a throttled or failed request is repeated.
+	MaxRetries int
+	// BaseBackoff is the delay before the first retry, it doubles with every further retry.
+	BaseBackoff time.Duration
+
+	next http.RoundTripper
+
+	budgetLock sync.RWMutex
+	remaining  int
+	reset      time.Time
+
+	remainingDesc *prometheus.Desc
+	retries       *prometheus.CounterVec
+}
+
+// NewRateLimiter creates a new rate limiter that sends its requests through the passed round tripper.
+func NewRateLimiter(next http.RoundTripper, reserve int) *RateLimiter {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &RateLimiter{
+		Reserve:     reserve,
+		MaxRetries:  defaultMaxRetries,
+		BaseBackoff: defaultBaseBackoff,
+		next:        next,
+		remaining:   -1,
+		remainingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("hcloud", "pricing_exporter", "api_ratelimit_remaining"),
+			"The number of requests that remain in the current rate limit window of the HCloud API",
+			nil,
+			nil,
+		),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "api_retries_total",
+			Help:      "The number of retried HCloud API requests per response status code",
+		}, []string{"code"}),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (limiter *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only requests without side effects can be repeated safely.
+	retryable := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	for attempt := 0; ; attempt++ {
+		resp, err := limiter.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		limiter.track(resp)
+
+		if !retryable || attempt >= limiter.MaxRetries || !shouldRetry(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := limiter.backoff(attempt, resp)
+		limiter.retries.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Remaining returns the number of requests left in the current rate limit window and the point in time at which the
+// window resets. The returned flag is false, as long as no response carried rate limit information.
+func (limiter *RateLimiter) Remaining() (remaining int, reset time.Time, ok bool) {
+	limiter.budgetLock.RLock()
+	defer limiter.budgetLock.RUnlock()
+
+	return limiter.remaining, limiter.reset, limiter.remaining >= 0
+}
+
+// Low reports whether the request budget fell below the configured reserve.
+func (limiter *RateLimiter) Low() bool {
+	remaining, reset, ok := limiter.Remaining()
+	return ok && remaining < limiter.Reserve && (reset.IsZero() || time.Now().Before(reset))
+}
+
+// Describe implements prometheus.Collector.
+func (limiter *RateLimiter) Describe(descs chan<- *prometheus.Desc) {
+	descs <- limiter.remainingDesc
+	limiter.retries.Describe(descs)
+}
+
+// Collect implements prometheus.Collector.
+func (limiter *RateLimiter) Collect(metrics chan<- prometheus.Metric) {
+	if remaining, _, ok := limiter.Remaining(); ok {
+		metrics <- prometheus.MustNewConstMetric(limiter.remainingDesc, prometheus.GaugeValue, float64(remaining))
+	}
+	limiter.retries.Collect(metrics)
+}
+
+func (limiter *RateLimiter) track(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	limiter.budgetLock.Lock()
+	defer limiter.budgetLock.Unlock()
+
+	limiter.remaining = remaining
+	if reset, err := strconv.ParseInt(resp.Header.Get("RateLimit-Reset"), 10, 64); err == nil {
+		limiter.reset = time.Unix(reset, 0)
+	}
+}
+
+// backoff calculates the delay before the next attempt. Throttled requests wait for the announced Retry-After, if it
+// is shorter than the maximum backoff.
+func (limiter *RateLimiter) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if delay := time.Duration(seconds) * time.Second; delay > 0 && delay <= defaultMaxBackoff {
+				return delay
+			}
+		}
+	}
+
+	delay := limiter.BaseBackoff << attempt
+	if delay <= 0 || delay > defaultMaxBackoff {
+		delay = defaultMaxBackoff
+	}
+
+	// Spread the retries of concurrent fetchers between half and the full delay.
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1)) //nolint:gosec // jitter needs no secure randomness
+}
+
+func shouldRetry(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,81 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For the rate limiter", func() {
+	var (
+		failures  atomic.Int32
+		remaining atomic.Int32
+		server    *httptest.Server
+		sut       *fetcher.RateLimiter
+		client    *http.Client
+	)
+
+	BeforeEach(func() {
+		failures.Store(0)
+		remaining.Store(3600)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(remaining.Add(-1))))
+			w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			if failures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+
+		sut = fetcher.NewRateLimiter(nil, 100)
+		sut.BaseBackoff = time.Millisecond
+		client = &http.Client{Transport: sut}
+	})
+
+	When("the API throttles requests", func() {
+		It("should retry them", func() {
+			failures.Store(2)
+
+			resp, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(testutil.CollectAndCount(sut, "hcloud_pricing_exporter_api_retries_total")).To(Equal(1))
+		})
+
+		It("should give up after the maximum number of retries", func() {
+			failures.Store(10)
+
+			resp, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		})
+	})
+
+	When("the request budget runs low", func() {
+		It("should report it", func() {
+			resp, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(sut.Low()).To(BeFalse())
+
+			remaining.Store(50)
+			resp, err = client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(sut.Low()).To(BeTrue())
+			Expect(testutil.ToFloat64(sut)).To(BeNumerically("==", 49))
+		})
+	})
+})
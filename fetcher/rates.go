@@ -0,0 +1,176 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateBaseCurrency is the currency that the exchange rates of the European Central Bank are relative to.
+const rateBaseCurrency = "EUR"
+
+// ExchangeRates holds the rates of currencies relative to the euro, as published for a single date.
+type ExchangeRates struct {
+	// Date is the day the rates were published for, formatted as YYYY-MM-DD.
+	Date string
+	// Source names the publisher of the rates, or the file they were read from if it does not say.
+	Source string
+
+	rates map[string]float64
+}
+
+// rate returns the factor that converts an amount in the first currency to the second currency.
+func (rates *ExchangeRates) rate(from, to string) (float64, error) {
+	fromRate, fromOK := rates.rates[from]
+	toRate, toOK := rates.rates[to]
+	switch {
+	case !fromOK:
+		return 0, fmt.Errorf("no exchange rate known for %s", from)
+	case !toOK:
+		return 0, fmt.Errorf("no exchange rate known for %s", to)
+	}
+	return toRate / fromRate, nil
+}
+
+// RatesFile reads exchange rates from a local file, which is either an XML or a CSV export of the reference rates of
+// the European Central Bank. If the file holds the rates of several days, the latest ones are used. The file is read
+// again, whenever it changes.
+type RatesFile struct {
+	file *watchedFile[*ExchangeRates]
+}
+
+// NewRatesFile creates a rates file and reads it right away, so that invalid files are reported early.
+func NewRatesFile(path string) (*RatesFile, error) {
+	file := &RatesFile{file: newWatchedFile(path, "rates file", readRatesFile)}
+	if _, err := file.get(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// get returns the latest exchange rates of the file and reads it again, if it changed since the last read.
+func (file *RatesFile) get() (*ExchangeRates, error) {
+	return file.file.get()
+}
+
+func readRatesFile(path string) (*ExchangeRates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var rates *ExchangeRates
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("<")) {
+		rates, err = parseECBXML(raw)
+	} else {
+		rates, err = parseECBCSV(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rates file %s: %w", path, err)
+	}
+
+	if rates.Source == "" {
+		rates.Source = filepath.Base(path)
+	}
+	rates.rates[rateBaseCurrency] = 1
+	return rates, nil
+}
+
+type ecbEnvelope struct {
+	Sender struct {
+		Name string `xml:"name"`
+	} `xml:"Sender"`
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// parseECBXML parses the format of eurofxref-daily.xml and eurofxref-hist.xml.
+func parseECBXML(raw []byte) (*ExchangeRates, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+
+	latest := -1
+	for i, day := range envelope.Days {
+		if latest < 0 || day.Time > envelope.Days[latest].Time {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return nil, errors.New("no exchange rates found")
+	}
+
+	day := envelope.Days[latest]
+	rates := &ExchangeRates{Date: day.Time, Source: envelope.Sender.Name, rates: map[string]float64{}}
+	for _, rate := range day.Rates {
+		value, err := strconv.ParseFloat(rate.Rate, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q of %s", rate.Rate, rate.Currency)
+		}
+		rates.rates[rate.Currency] = value
+	}
+	return rates, nil
+}
+
+// parseECBCSV parses the format of eurofxref.csv and eurofxref-hist.csv: a header row that names the currencies after
+// a date column and one row of rates per day. Missing rates are marked as N/A.
+func parseECBCSV(raw []byte) (*ExchangeRates, error) {
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 || !strings.EqualFold(records[0][0], "date") {
+		return nil, errors.New("expected a header row starting with Date and at least one row of rates")
+	}
+
+	header := records[0]
+	var latest []string
+	var latestDate time.Time
+	for _, record := range records[1:] {
+		date, err := parseRateDate(record[0])
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || date.After(latestDate) {
+			latest, latestDate = record, date
+		}
+	}
+
+	rates := &ExchangeRates{Date: latestDate.Format(time.DateOnly), rates: map[string]float64{}}
+	for i, currency := range header[1:] {
+		if currency == "" || i+1 >= len(latest) || latest[i+1] == "N/A" || latest[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(latest[i+1], 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q of %s", latest[i+1], currency)
+		}
+		rates.rates[currency] = value
+	}
+	return rates, nil
+}
+
+func parseRateDate(raw string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, "02 January 2006"} {
+		if date, err := time.Parse(layout, raw); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", raw)
+}
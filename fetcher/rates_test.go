@@ -0,0 +1,95 @@
+package fetcher_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For exchange rates", func() {
+	var client *hcloud.Client
+
+	BeforeEach(func() {
+		_, client = newFakeAPI(map[string]int{"volumes": 1})
+	})
+
+	It("should refuse an invalid file", func() {
+		_, err := fetcher.NewRatesFile(writePricingFile("Currency,Rate\nUSD,1.07\n"))
+		Expect(err).To(MatchError(ContainSubstring("expected a header row starting with Date")))
+	})
+
+	DescribeTable("should convert prices with the latest rates",
+		func(ctx context.Context, path, currency string, rate float64) {
+			rates, err := fetcher.NewRatesFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			pricing := &fetcher.PriceProvider{Client: client, Currency: currency, Rates: rates}
+
+			Expect(gross(pricing.Volume(ctx))).To(BeNumerically("~", 0.05236*rate, 1e-6))
+		},
+		Entry("of an ECB XML export", "testdata/eurofxref-daily.xml", "USD", 1.0708),
+		Entry("of an ECB CSV export", "testdata/eurofxref-hist.csv", "CHF", 0.9780),
+		Entry("without a conversion", "testdata/eurofxref-hist.csv", "EUR", 1.0),
+	)
+
+	It("should fail, if the rates lack the currency", func(ctx context.Context) {
+		rates, err := fetcher.NewRatesFile("testdata/eurofxref-daily.xml")
+		Expect(err).NotTo(HaveOccurred())
+		pricing := &fetcher.PriceProvider{Client: client, Currency: "GBP", Rates: rates}
+
+		_, err = pricing.Volume(ctx)
+		Expect(err).To(MatchError(ContainSubstring("no exchange rate known for GBP")))
+	})
+
+	It("should label every cost series with the currency and expose the rate", func(ctx context.Context) {
+		rates, err := fetcher.NewRatesFile("testdata/eurofxref-daily.xml")
+		Expect(err).NotTo(HaveOccurred())
+		pricing := &fetcher.PriceProvider{Client: client, Currency: "USD", Rates: rates}
+		monitor := fetcher.NewMonitor(pricing)
+
+		volume := fetcher.NewVolume(pricing)
+		Expect(fetcher.Fetchers{volume}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(labelValues(volume, "hcloud_pricing_volume_monthly", fetcher.CurrencyLabel)).To(ConsistOf("USD"))
+		Expect(testutil.CollectAndCompare(monitor, strings.NewReader(`
+# HELP hcloud_pricing_exporter_exchange_rate_info The date and the source of the exchange rate that converts the prices to the exposed currency
+# TYPE hcloud_pricing_exporter_exchange_rate_info gauge
+hcloud_pricing_exporter_exchange_rate_info{date="2024-05-02",from="EUR",source="European Central Bank",to="USD"} 1
+`), "hcloud_pricing_exporter_exchange_rate_info")).To(Succeed())
+	})
+
+	It("should label costs with the currency of the pricing, if they are not converted", func(ctx context.Context) {
+		volume := fetcher.NewVolume(&fetcher.PriceProvider{Client: client})
+		Expect(fetcher.Fetchers{volume}.Run(ctx, client, fetcher.RunOptions{})).To(Succeed())
+
+		Expect(labelValues(volume, "hcloud_pricing_volume_monthly", fetcher.CurrencyLabel)).To(ConsistOf("EUR"))
+	})
+})
+
+// labelValues gathers the values of a label of all series of the named metric that the collector exposes.
+func labelValues(collector prometheus.Collector, metricName, labelName string) []string {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	var values []string
+	for _, family := range families {
+		if family.GetName() != metricName {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == labelName {
+					values = append(values, label.GetValue())
+				}
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+package fetcher
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Constructor creates a new fetcher that uses the passed prices and exposes the passed additional labels.
+type Constructor func(pricing *PriceProvider, additionalLabels ...string) Fetcher
+
+type registration struct {
+	name        string
+	constructor Constructor
+}
+
+// registry lists all known fetchers by the name of the resource type they collect costs for.
+var registry = []registration{
+	{name: "floatingip", constructor: NewFloatingIP},
+	{name: "primaryip", constructor: NewPrimaryIP},
+	{name: "loadbalancer", constructor: NewLoadbalancer},
+	{name: "loadbalancer_traffic", constructor: NewLoadbalancerTraffic},
+	{name: "server", constructor: NewServer},
+	{name: "server_backup", constructor: NewServerBackup},
+	{name: "server_traffic", constructor: NewServerTraffic},
+	{name: "snapshot", constructor: NewSnapshot},
+	{name: "volume", constructor: NewVolume},
+}
+
+// Names returns the names of all known fetchers.
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, registration := range registry {
+		names[i] = registration.name
+	}
+	return names
+}
+
+// LabelNames returns the names of the labels that the fetchers expose on their own, which additional labels must not
+// reuse.
+func LabelNames() []string {
+	var names []string
+	for _, registration := range registry {
+		fetcher := registration.constructor(nil)
+		if based, ok := fetcher.(basedFetcher); ok {
+			for _, label := range based.base().labels {
+				if !slices.Contains(names, label) {
+					names = append(names, label)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Known reports whether a fetcher with the passed name exists.
+func Known(name string) bool {
+	return slices.Contains(Names(), name)
+}
+
+// Select returns the names of the fetchers that are listed as enabled, or of all fetchers if none are, without the
+// ones that are listed as disabled.
+func Select(enabled, disabled []string) ([]string, error) {
+	for _, name := range slices.Concat(enabled, disabled) {
+		if !Known(name) {
+			return nil, fmt.Errorf("unknown fetcher %q, expected one of %v", name, Names())
+		}
+	}
+
+	var selected []string
+	for _, name := range Names() {
+		if (len(enabled) == 0 || slices.Contains(enabled, name)) && !slices.Contains(disabled, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
+
+// New creates the fetchers with the passed names.
+func New(names []string, pricing *PriceProvider, additionalLabels ...string) (Fetchers, error) {
+	fetchers := make(Fetchers, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(registry, func(registration registration) bool { return registration.name == name })
+		if index < 0 {
+			return nil, fmt.Errorf("unknown fetcher %q, expected one of %v", name, Names())
+		}
+
+		fetchers = append(fetchers, registry[index].constructor(pricing, additionalLabels...))
+	}
+	return fetchers, nil
+}
@@ -0,0 +1,44 @@
+package fetcher_test
+
+import (
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("For the fetcher registry", func() {
+	It("should create fetchers that carry their registered name", func() {
+		fetchers, err := fetcher.New(fetcher.Names(), &fetcher.PriceProvider{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetchers).To(HaveLen(len(fetcher.Names())))
+
+		for i, name := range fetcher.Names() {
+			Expect(fetchers[i].Name()).To(Equal(name))
+		}
+	})
+
+	It("should list the labels of all fetchers", func() {
+		Expect(fetcher.LabelNames()).To(ConsistOf("name", "location", "type", "datacenter", "bytes"))
+	})
+
+	It("should reject unknown names", func() {
+		_, err := fetcher.New([]string{"server", "servers"}, &fetcher.PriceProvider{})
+		Expect(err).To(MatchError(ContainSubstring(`unknown fetcher "servers"`)))
+
+		_, err = fetcher.Select(nil, []string{"traffic"})
+		Expect(err).To(MatchError(ContainSubstring(`unknown fetcher "traffic"`)))
+	})
+
+	DescribeTable("should select fetchers",
+		func(enabled, disabled, expected []string) {
+			Expect(fetcher.Select(enabled, disabled)).To(Equal(expected))
+		},
+		Entry("all by default", nil, nil, fetcher.Names()),
+		Entry("only the enabled ones", []string{"volume", "server"}, nil, []string{"server", "volume"}),
+		Entry("all but the disabled ones", nil,
+			[]string{"floatingip", "primaryip", "loadbalancer", "loadbalancer_traffic", "server_traffic"},
+			[]string{"server", "server_backup", "snapshot", "volume"}),
+		Entry("enabled ones without the disabled ones", []string{"server", "server_traffic"}, []string{"server_traffic"},
+			[]string{"server"}),
+	)
+})
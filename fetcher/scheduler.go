@@ -0,0 +1,63 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+)
+
+// Schedule runs a first data fetching cycle right away and repeats it for every fetcher in its own interval, until the
+// passed context is done. Fetchers that share an interval run together, so that they share the responses of the API.
+// A fetcher whose previous cycle is still in-flight skips the tick. The returned function blocks until all cycles
+// finished, once the context is done.
+func (fetchers Fetchers) Schedule(
+	ctx context.Context, client *hcloud.Client, interval func(fetcher string) time.Duration, opts RunOptions,
+) (wait func()) {
+	var intervals []time.Duration
+	groups := map[time.Duration]Fetchers{}
+	for _, fetcher := range fetchers {
+		fetcherInterval := interval(fetcher.Name())
+		if _, ok := groups[fetcherInterval]; !ok {
+			intervals = append(intervals, fetcherInterval)
+		}
+		groups[fetcherInterval] = append(groups[fetcherInterval], fetcher)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, groupInterval := range intervals {
+		wg.Add(1)
+		go func(group Fetchers) {
+			defer wg.Done()
+			group.runAtInterval(ctx, client, groupInterval, opts, wg)
+		}(groups[groupInterval])
+	}
+	return wg.Wait
+}
+
+func (fetchers Fetchers) runAtInterval(
+	ctx context.Context, client *hcloud.Client, interval time.Duration, opts RunOptions, wg *sync.WaitGroup,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Cycles run detached from the ticker, so that a slow fetcher does not delay the others of its group.
+	run := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetchers.MustRun(ctx, client, opts)
+		}()
+	}
+
+	run()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fetcher_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For scheduled fetchers", func() {
+	var (
+		api      *fakeAPI
+		client   *hcloud.Client
+		fetchers fetcher.Fetchers
+	)
+
+	BeforeEach(func() {
+		api, client = newFakeAPI(map[string]int{"volumes": 3, "floating_ips": 2})
+		pricing := &fetcher.PriceProvider{Client: client}
+		fetchers = fetcher.Fetchers{fetcher.NewVolume(pricing), fetcher.NewFloatingIP(pricing)}
+	})
+
+	It("should run every fetcher in its own interval", func(ctx context.Context) {
+		intervals := map[string]time.Duration{"volume": 20 * time.Millisecond, "floatingip": time.Hour}
+		fetchers.Schedule(ctx, client, func(name string) time.Duration { return intervals[name] }, fetcher.RunOptions{})
+
+		Eventually(func() int { return api.Requests("volumes") }).Should(BeNumerically(">=", 3))
+		Expect(api.Requests("floating_ips")).To(Equal(1))
+	})
+
+	It("should wait for the cycle in-flight, once it is cancelled", func(ctx context.Context) {
+		release := api.Block("volumes")
+		DeferCleanup(release)
+		scheduleCtx, cancel := context.WithCancel(ctx)
+		wait := fetchers.Schedule(scheduleCtx, client, func(string) time.Duration { return time.Hour }, fetcher.RunOptions{})
+		Eventually(func() int { return api.Requests("volumes") }).Should(Equal(1))
+
+		waited := make(chan struct{})
+		go func() {
+			wait()
+			close(waited)
+		}()
+		Consistently(waited, "50ms").ShouldNot(BeClosed())
+
+		cancel()
+		Eventually(waited).Should(BeClosed())
+	})
+
+	It("should skip a fetcher while its previous run is in-flight", func(ctx context.Context) {
+		monitor := fetcher.NewMonitor(&fetcher.PriceProvider{Client: client})
+		opts := fetcher.RunOptions{Monitor: monitor}
+
+		release := api.Block("volumes")
+		done := make(chan error)
+		go func() { done <- fetchers[:1].Run(ctx, client, opts) }()
+		Eventually(func() int { return api.Requests("volumes") }).Should(Equal(1))
+
+		Expect(fetchers.Run(ctx, client, opts)).To(Succeed())
+		Expect(api.Requests("volumes")).To(Equal(1))
+		Expect(api.Requests("floating_ips")).To(Equal(1))
+
+		release()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(testutil.CollectAndCompare(monitor, strings.NewReader(`
+# HELP hcloud_pricing_exporter_fetch_skipped_total The number of skipped data fetching cycles per fetcher, whose previous cycle was still running
+# TYPE hcloud_pricing_exporter_fetch_skipped_total counter
+hcloud_pricing_exporter_fetch_skipped_total{fetcher="floatingip"} 0
+hcloud_pricing_exporter_fetch_skipped_total{fetcher="volume"} 1
+`), "hcloud_pricing_exporter_fetch_skipped_total")).To(Succeed())
+
+		Expect(fetchers.Run(ctx, client, opts)).To(Succeed())
+		Expect(api.Requests("volumes")).To(Equal(2))
+	})
+})
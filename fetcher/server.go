@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hetznercloud/hcloud-go/hcloud"
@@ -17,35 +18,13 @@ type server struct {
 	*baseFetcher
 }
 
-func getServer(client *hcloud.Client) ([]*hcloud.Server, error) {
-	page := 0
-	var result []*hcloud.Server
-	for {
-
-		servers, response, err := client.Server.List(ctx, hcloud.ServerListOpts{
-			ListOpts: hcloud.ListOpts{
-				Page:    page,
-				PerPage: 50,
-			},
-		})
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, servers...)
-		if page == response.Meta.Pagination.LastPage {
-			break
-		}
-		page++
-	}
-	return result, nil
-}
-
-func (server server) Run(client *hcloud.Client) error {
-	servers, err := getServer(client)
+func (server server) Run(ctx context.Context, inventory *Inventory) error {
+	servers, err := inventory.Servers(ctx)
 	if err != nil {
 		return err
 	}
 
+	result := newSnapshot()
 	for _, s := range servers {
 		location := s.Datacenter.Location
 
@@ -56,25 +35,31 @@ func (server server) Run(client *hcloud.Client) error {
 		},
 			parseAdditionalLabels(server.additionalLabels, s.Labels)...,
 		)
-		pricing, err := findServerPricing(location, s.ServerType.Pricings)
+		hourly, monthly, err := server.pricing.Server(ctx, s.ServerType, location.Name)
 		if err != nil {
 			return err
 		}
 
-		parseToGauge(server.hourly.WithLabelValues(labels...), pricing.Hourly.Gross)
-		parseToGauge(server.monthly.WithLabelValues(labels...), pricing.Monthly.Gross)
+		info := resourceInfo{
+			id:         s.ID,
+			serverType: s.ServerType.Name,
+			location:   location.Name,
+			labels:     s.Labels,
+			created:    s.Created,
+		}
+		server.record(result, info, labels, hourly, monthly)
 	}
 
+	server.publish(result)
 	return nil
 }
 
-func findServerPricing(location *hcloud.Location, pricings []hcloud.ServerTypeLocationPricing) (*hcloud.ServerTypeLocationPricing, error) {
+func findServerPricing(location string, pricings []hcloud.ServerTypeLocationPricing) (*hcloud.ServerTypeLocationPricing, error) {
 	for _, pricing := range pricings {
-		if pricing.Location.Name == location.Name {
+		if pricing.Location.Name == location {
 			return &pricing, nil
 		}
 	}
 
-	return nil, fmt.Errorf("no server pricing found for location %s", location.Name)
+	return nil, fmt.Errorf("no server pricing found for location %s", location)
 }
-
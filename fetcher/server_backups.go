@@ -1,11 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"strconv"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &serverBackup{}
@@ -19,17 +17,18 @@ type serverBackup struct {
 	*baseFetcher
 }
 
-func (serverBackup serverBackup) Run(client *hcloud.Client) error {
-	servers, err := getServer(client) // Use existing helper
+func (serverBackup serverBackup) Run(ctx context.Context, inventory *Inventory) error {
+	servers, err := inventory.Servers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list servers for backup pricing: %w", err)
 	}
 
-	backupPercentage, err := serverBackup.pricing.ServerBackup() // Get price once
+	backupPercentage, err := serverBackup.pricing.ServerBackup(ctx) // Get price once
 	if err != nil {
 		return fmt.Errorf("could not get server backup pricing: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, s := range servers {
 		location := s.Datacenter.Location
 
@@ -40,39 +39,39 @@ func (serverBackup serverBackup) Run(client *hcloud.Client) error {
 		},
 			parseAdditionalLabels(serverBackup.additionalLabels, s.Labels)...,
 		)
+		info := resourceInfo{
+			id:         s.ID,
+			serverType: s.ServerType.Name,
+			location:   location.Name,
+			labels:     s.Labels,
+			created:    s.Created,
+		}
 
 		if s.BackupWindow != "" {
-			serverPriceInfo, err := findServerPricing(location, s.ServerType.Pricings)
+			serverHourly, serverMonthly, err := serverBackup.pricing.Server(ctx, s.ServerType, location.Name)
 			if err != nil {
 				// Log or return error? Return seems consistent.
 				log.Printf("Could not find server pricing for %s (%s) needed for backup calculation: %v", s.Name, location.Name, err)
 				return fmt.Errorf("could not find server pricing for %s (%s) needed for backup calculation: %w", s.Name, location.Name, err)
 			}
 
-			// Use the adjusted helper function
-			hourlyPrice := calculateBackupPrice(serverPriceInfo.Hourly.Gross, backupPercentage)
-			monthlyPrice := calculateBackupPrice(serverPriceInfo.Monthly.Gross, backupPercentage)
+			hourlyPrice := calculateBackupPrice(serverHourly, backupPercentage)
+			monthlyPrice := calculateBackupPrice(serverMonthly, backupPercentage)
 
-			serverBackup.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-			serverBackup.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+			serverBackup.record(result, info, labels, hourlyPrice, monthlyPrice)
 		} else {
-			serverBackup.hourly.WithLabelValues(labels...).Set(0)
-			serverBackup.monthly.WithLabelValues(labels...).Set(0)
+			serverBackup.record(result, info, labels, Price{}, Price{})
 		}
 	}
 
+	serverBackup.publish(result)
 	return nil
 }
 
 // calculateBackupPrice calculates the backup price based on server price and backup percentage.
-func calculateBackupPrice(rawServerPrice string, backupPercentage float64) float64 {
-	serverPrice, err := strconv.ParseFloat(rawServerPrice, 64) // Use 64 bit
-	if err != nil {
-		log.Printf("Error parsing server price '%s' for backup calculation: %v", rawServerPrice, err)
-		return 0
-	}
+func calculateBackupPrice(serverPrice Price, backupPercentage float64) Price {
 	if backupPercentage <= 0 {
-		return 0
+		return Price{}
 	}
-	return serverPrice * (backupPercentage / 100)
+	return serverPrice.times(backupPercentage / 100)
 }
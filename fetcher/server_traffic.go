@@ -1,11 +1,10 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &serverTraffic{}
@@ -19,18 +18,19 @@ type serverTraffic struct {
 	*baseFetcher
 }
 
-func (serverTraffic serverTraffic) Run(client *hcloud.Client) error {
-	servers, err := getServer(client) // Use existing helper
+func (serverTraffic serverTraffic) Run(ctx context.Context, inventory *Inventory) error {
+	servers, err := inventory.Servers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list servers for traffic pricing: %w", err)
 	}
 
-	trafficPricePerTB, err := serverTraffic.pricing.Traffic()
+	trafficPricePerTB, err := serverTraffic.pricing.Traffic(ctx)
 	if err != nil {
 		log.Printf("Could not get traffic pricing: %v", err)
 		return fmt.Errorf("could not get traffic pricing: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, s := range servers {
 		location := s.Datacenter.Location
 
@@ -41,20 +41,26 @@ func (serverTraffic serverTraffic) Run(client *hcloud.Client) error {
 		},
 			parseAdditionalLabels(serverTraffic.additionalLabels, s.Labels)...,
 		)
+		info := resourceInfo{
+			id:         s.ID,
+			serverType: s.ServerType.Name,
+			location:   location.Name,
+			labels:     s.Labels,
+			created:    s.Created,
+		}
 
 		additionalTraffic := int(s.OutgoingTraffic) - int(s.IncludedTraffic)
 		if additionalTraffic < 0 {
-			serverTraffic.hourly.WithLabelValues(labels...).Set(0)
-			serverTraffic.monthly.WithLabelValues(labels...).Set(0)
+			serverTraffic.record(result, info, labels, Price{}, Price{})
 			continue // Use continue instead of break to process other servers
 		}
 
-		monthlyPrice := math.Ceil(float64(additionalTraffic)/sizeTB) * trafficPricePerTB
+		monthlyPrice := trafficPricePerTB.times(math.Ceil(float64(additionalTraffic) / sizeTB))
 		hourlyPrice := pricingPerHour(monthlyPrice)
 
-		serverTraffic.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-		serverTraffic.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+		serverTraffic.record(result, info, labels, hourlyPrice, monthlyPrice)
 	}
 
+	serverTraffic.publish(result)
 	return nil
 }
@@ -1,10 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &snapshot{}
@@ -18,21 +17,22 @@ type snapshot struct {
 	*baseFetcher
 }
 
-func (snapshot snapshot) Run(client *hcloud.Client) error {
-	images, _, err := client.Image.List(ctx, hcloud.ImageListOpts{})
+func (snapshot snapshot) Run(ctx context.Context, inventory *Inventory) error {
+	images, err := inventory.Images(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list images for snapshot pricing: %w", err)
 	}
 
-	snapshotPricePerGB, err := snapshot.pricing.Image()
+	snapshotPricePerGB, err := snapshot.pricing.Image(ctx)
 	if err != nil {
 		log.Printf("Could not get snapshot/image pricing: %v", err)
 		return fmt.Errorf("could not get snapshot/image pricing: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, i := range images {
 		if i.Type == "snapshot" {
-			monthlyPrice := float64(i.ImageSize) * snapshotPricePerGB
+			monthlyPrice := snapshotPricePerGB.times(float64(i.ImageSize))
 			hourlyPrice := pricingPerHour(monthlyPrice)
 
 			labels := append([]string{
@@ -41,10 +41,11 @@ func (snapshot snapshot) Run(client *hcloud.Client) error {
 				parseAdditionalLabels(snapshot.additionalLabels, i.Labels)...,
 			)
 
-			snapshot.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-			snapshot.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+			info := resourceInfo{id: i.ID, labels: i.Labels, created: i.Created}
+			snapshot.record(result, info, labels, hourlyPrice, monthlyPrice)
 		}
 	}
 
+	snapshot.publish(result)
 	return nil
 }
@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateStore persists the last successful responses of the HCloud API, so that a restarted exporter can serve costs
+// before its first data fetching cycle completes. A nil store persists nothing.
+type StateStore struct {
+	dir string
+}
+
+type stateFile[T any] struct {
+	SavedAt time.Time `json:"saved_at"`
+	Data    T         `json:"data"`
+}
+
+// NewStateStore creates a store that keeps its files in the passed directory, which is created if necessary.
+func NewStateStore(dir string) (*StateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	return &StateStore{dir: dir}, nil
+}
+
+// saveState replaces the named file of the store in a single step, so that readers never see a partial file.
+func saveState[T any](store *StateStore, name string, data T) error {
+	if store == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(stateFile[T]{SavedAt: time.Now(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode state %s: %w", name, err)
+	}
+
+	file, err := os.CreateTemp(store.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	defer os.Remove(file.Name()) //nolint:errcheck // the file is already renamed, if everything went well
+
+	if _, err := file.Write(raw); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	if err := os.Rename(file.Name(), filepath.Join(store.dir, name+".json")); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadState reads the named file of the store. The returned flag is false, if the file does not exist.
+func loadState[T any](store *StateStore, name string) (data T, savedAt time.Time, ok bool, err error) {
+	if store == nil {
+		return data, savedAt, false, nil
+	}
+
+	raw, err := os.ReadFile(filepath.Join(store.dir, name+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return data, savedAt, false, nil
+	}
+	if err != nil {
+		return data, savedAt, false, fmt.Errorf("failed to read state %s: %w", name, err)
+	}
+
+	var file stateFile[T]
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return data, savedAt, false, fmt.Errorf("failed to decode state %s: %w", name, err)
+	}
+	return file.Data, file.SavedAt, true, nil
+}
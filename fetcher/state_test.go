@@ -0,0 +1,84 @@
+package fetcher_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For a persisted state", func() {
+	var (
+		client *hcloud.Client
+		state  *fetcher.StateStore
+	)
+
+	BeforeEach(func() {
+		_, client = newFakeAPI(map[string]int{"volumes": 3, "servers": 2})
+
+		var err error
+		state, err = fetcher.NewStateStore(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should restore nothing, if nothing was persisted", func() {
+		Expect((&fetcher.PriceProvider{State: state}).Restore()).To(BeFalse())
+
+		_, ok, err := fetcher.RestoreInventory(state)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	When("the exporter restarts", func() {
+		var (
+			offlineAPI    *fakeAPI
+			offlineClient *hcloud.Client
+		)
+
+		BeforeEach(func(ctx context.Context) {
+			fetchers := fetcher.Fetchers{fetcher.NewVolume(&fetcher.PriceProvider{Client: client, State: state})}
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+			offlineAPI, offlineClient = newFakeAPI(map[string]int{})
+			offlineAPI.Fail("pricing", true)
+		})
+
+		It("should serve the persisted costs as stale without calling the API", func(ctx context.Context) {
+			pricing := &fetcher.PriceProvider{Client: offlineClient, State: state}
+			Expect(pricing.Restore()).To(BeTrue())
+			inventory, ok, err := fetcher.RestoreInventory(state)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			volume := fetcher.NewVolume(pricing)
+			server := fetcher.NewServer(pricing)
+			fetcher.Fetchers{volume, server}.Restore(ctx, inventory)
+
+			Expect(testutil.CollectAndCount(volume, "hcloud_pricing_volume_hourly")).To(Equal(3))
+			Expect(testutil.CollectAndCompare(volume, staleMetric("volume", 1), "hcloud_pricing_stale")).To(Succeed())
+			Expect(volume.Snapshot().Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(offlineAPI.Requests("volumes")).To(BeZero())
+
+			// Servers were never persisted, so their costs stay unknown.
+			Expect(testutil.CollectAndCount(server, "hcloud_pricing_server_hourly")).To(BeZero())
+		})
+
+		It("should replace the persisted costs with the first successful cycle", func(ctx context.Context) {
+			pricing := &fetcher.PriceProvider{Client: client, State: state}
+			Expect(pricing.Restore()).To(BeTrue())
+			inventory, _, err := fetcher.RestoreInventory(state)
+			Expect(err).NotTo(HaveOccurred())
+
+			volume := fetcher.NewVolume(pricing)
+			fetchers := fetcher.Fetchers{volume}
+			fetchers.Restore(ctx, inventory)
+			Expect(fetchers.Run(ctx, client, fetcher.RunOptions{State: state})).To(Succeed())
+
+			Expect(testutil.CollectAndCompare(volume, staleMetric("volume", 0), "hcloud_pricing_stale")).To(Succeed())
+		})
+	})
+})
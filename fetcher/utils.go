@@ -1,20 +1,15 @@
 package fetcher
 
 import (
-	"log"
-	"strconv"
 	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
 	sizeTB = 1 << (10 * 4)
 )
 
-func daysInMonth() int {
-	now := time.Now()
-
+// daysInMonth returns the number of days of the month that the passed point in time belongs to.
+func daysInMonth(now time.Time) int {
 	switch now.Month() {
 	case time.April, time.June, time.September, time.November:
 		return 30
@@ -29,16 +24,8 @@ func daysInMonth() int {
 	}
 }
 
-func pricingPerHour(monthlyPrice float64) float64 {
-	return monthlyPrice / float64(daysInMonth()) / 24
-}
-
-func parseToGauge(gauge prometheus.Gauge, value string) {
-	parsed, err := strconv.ParseFloat(value, 32)
-	if err != nil {
-		log.Println(err)
-	}
-	gauge.Set(parsed)
+func pricingPerHour(monthlyPrice Price) Price {
+	return monthlyPrice.times(1 / float64(daysInMonth(time.Now())) / 24)
 }
 
 func parseAdditionalLabels(additionalLabels []string, labels map[string]string) (result []string) {
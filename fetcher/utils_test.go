@@ -0,0 +1,228 @@
+package fetcher_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	fakePageSize = 10
+)
+
+// fakeAPI serves a configurable amount of resources per listing endpoint, split into pages of a fixed size that
+// ignores the requested page size, just like the real API is allowed to.
+type fakeAPI struct {
+	lock      sync.Mutex
+	resources map[string]int
+	requests  map[string]int
+	queries   map[string]url.Values
+	failing   map[string]bool
+	blocked   map[string]chan struct{}
+	pricing   []byte
+	created   time.Time
+	changes   map[string]map[string]interface{}
+}
+
+func newFakeAPI(resources map[string]int) (*fakeAPI, *hcloud.Client) {
+	pricing, err := os.ReadFile("testdata/pricing.json")
+	Expect(err).NotTo(HaveOccurred())
+
+	api := &fakeAPI{
+		resources: resources,
+		requests:  map[string]int{},
+		queries:   map[string]url.Values{},
+		failing:   map[string]bool{},
+		blocked:   map[string]chan struct{}{},
+		pricing:   pricing,
+		created:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		changes:   map[string]map[string]interface{}{},
+	}
+
+	server := httptest.NewServer(api)
+	DeferCleanup(server.Close)
+
+	return api, hcloud.NewClient(hcloud.WithEndpoint(server.URL), hcloud.WithToken("fake"))
+}
+
+func (api *fakeAPI) Requests(endpoint string) int {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	return api.requests[endpoint]
+}
+
+// Query returns the query parameters of the last request to the endpoint.
+func (api *fakeAPI) Query(endpoint string) url.Values {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	return api.queries[endpoint]
+}
+
+func (api *fakeAPI) SetResources(endpoint string, total int) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.resources[endpoint] = total
+}
+
+// SetCreated changes the point in time at which all resources were created.
+func (api *fakeAPI) SetCreated(created time.Time) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.created = created
+}
+
+// Change replaces an attribute of all resources of the endpoint, e.g. to resize volumes.
+func (api *fakeAPI) Change(endpoint, attribute string, value interface{}) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if api.changes[endpoint] == nil {
+		api.changes[endpoint] = map[string]interface{}{}
+	}
+	api.changes[endpoint][attribute] = value
+}
+
+func (api *fakeAPI) Fail(endpoint string, failing bool) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.failing[endpoint] = failing
+}
+
+// Block holds all requests to the endpoint until the returned function is called.
+func (api *fakeAPI) Block(endpoint string) (release func()) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	blocked := make(chan struct{})
+	api.blocked[endpoint] = blocked
+	return func() {
+		api.lock.Lock()
+		defer api.lock.Unlock()
+
+		delete(api.blocked, endpoint)
+		close(blocked)
+	}
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.Trim(r.URL.Path, "/")
+
+	api.lock.Lock()
+	total, ok := api.resources[endpoint]
+	failing := api.failing[endpoint]
+	blocked, isBlocked := api.blocked[endpoint]
+	created := api.created
+	changes := maps.Clone(api.changes[endpoint])
+	api.requests[endpoint]++
+	api.queries[endpoint] = r.URL.Query()
+	api.lock.Unlock()
+
+	if isBlocked {
+		<-blocked
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case failing:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error": {"code": "unavailable", "message": "fake outage"}}`))
+	case endpoint == "pricing":
+		_, _ = w.Write(api.pricing)
+	case ok:
+		api.servePage(w, r, endpoint, total, created, changes)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (api *fakeAPI) servePage(
+	w http.ResponseWriter, r *http.Request, endpoint string, total int, created time.Time, changes map[string]interface{},
+) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	lastPage := (total + fakePageSize - 1) / fakePageSize
+	nextPage := page + 1
+	if nextPage > lastPage {
+		nextPage = 0
+	}
+
+	items := []map[string]interface{}{}
+	for id := (page-1)*fakePageSize + 1; id <= page*fakePageSize && id <= total; id++ {
+		item := fakeResource(endpoint, id, created)
+		maps.Copy(item, changes)
+		items = append(items, item)
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		endpoint: items,
+		"meta": map[string]interface{}{
+			"pagination": map[string]interface{}{
+				"page":          page,
+				"per_page":      fakePageSize,
+				"next_page":     nextPage,
+				"last_page":     lastPage,
+				"total_entries": total,
+			},
+		},
+	})
+}
+
+// fakeResource renders a resource with just enough attributes for the fetchers to price it.
+func fakeResource(endpoint string, id int, created time.Time) map[string]interface{} {
+	location := map[string]interface{}{"name": "fsn1"}
+	datacenter := map[string]interface{}{"name": "fsn1-dc14", "location": location}
+	prices := []map[string]interface{}{{
+		"location":      "fsn1",
+		"price_hourly":  map[string]string{"net": "0.0060000000", "gross": "0.0071400000000000"},
+		"price_monthly": map[string]string{"net": "3.7900000000", "gross": "4.5101000000000000"},
+	}}
+
+	resource := map[string]interface{}{
+		"id":      id,
+		"name":    fmt.Sprintf("%s-%d", endpoint, id),
+		"created": created.Format(time.RFC3339),
+		"labels":  map[string]string{"team": "fake"},
+	}
+	switch endpoint {
+	case "servers":
+		resource["datacenter"] = datacenter
+		resource["server_type"] = map[string]interface{}{"name": "cx22", "prices": prices}
+		resource["backup_window"] = "22-02"
+	case "load_balancers":
+		resource["location"] = location
+		resource["load_balancer_type"] = map[string]interface{}{"name": "lb11", "prices": prices}
+	case "volumes":
+		resource["location"] = location
+		resource["size"] = 10
+	case "floating_ips":
+		resource["home_location"] = location
+		resource["type"] = "ipv4"
+	case "primary_ips":
+		resource["datacenter"] = datacenter
+		resource["type"] = "ipv4"
+	case "images":
+		resource["type"] = "snapshot"
+		resource["image_size"] = 1.5
+	}
+
+	return resource
+}
@@ -1,11 +1,10 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
-
-	"github.com/hetznercloud/hcloud-go/hcloud"
 )
 
 var _ Fetcher = &volume{}
@@ -19,20 +18,21 @@ type volume struct {
 	*baseFetcher
 }
 
-func (volume volume) Run(client *hcloud.Client) error {
-	volumes, _, err := client.Volume.List(ctx, hcloud.VolumeListOpts{})
+func (volume volume) Run(ctx context.Context, inventory *Inventory) error {
+	volumes, err := inventory.Volumes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list volumes: %w", err)
 	}
 
-	volumePricePerGB, err := volume.pricing.Volume()
+	volumePricePerGB, err := volume.pricing.Volume(ctx)
 	if err != nil {
 		log.Printf("Could not get volume pricing: %v", err)
 		return fmt.Errorf("could not get volume pricing: %w", err)
 	}
 
+	result := newSnapshot()
 	for _, v := range volumes {
-		monthlyPrice := float64(v.Size) * volumePricePerGB
+		monthlyPrice := volumePricePerGB.times(float64(v.Size))
 		hourlyPrice := pricingPerHour(monthlyPrice)
 
 		labels := append([]string{
@@ -43,9 +43,10 @@ func (volume volume) Run(client *hcloud.Client) error {
 			parseAdditionalLabels(volume.additionalLabels, v.Labels)...,
 		)
 
-		volume.hourly.WithLabelValues(labels...).Set(hourlyPrice)
-		volume.monthly.WithLabelValues(labels...).Set(monthlyPrice)
+		info := resourceInfo{id: v.ID, location: v.Location.Name, labels: v.Labels, created: v.Created}
+		volume.record(result, info, labels, hourlyPrice, monthlyPrice)
 	}
 
+	volume.publish(result)
 	return nil
 }
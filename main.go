@@ -1,102 +1,352 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/config"
 	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
-	"github.com/jtaczanowski/go-scheduler"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
-	defaultPort          = 8080
-	defaultFetchInterval = 1 * time.Minute
-	defaultTimeout       = 5 * time.Second
+	defaultTimeout = 5 * time.Second
+	envPrefix      = "HCLOUD_PRICING_"
 )
 
-var (
-	hcloudAPIToken       string
-	port                 uint
-	fetchInterval        time.Duration
-	additionalLabelsFlag string
-	additionalLabels     []string
-)
+// loadConfig builds the configuration of the exporter. Values are taken from the defaults, the config file,
+// environment variables and command line flags, where each source overrides the ones before it.
+func loadConfig(args []string) (*config.Config, error) {
+	configFile := lookupConfigFile(args)
+	cfg := config.Default()
+	if configFile != "" {
+		var err error
+		if cfg, err = config.Load(configFile); err != nil {
+			return nil, err
+		}
+	}
 
-func handleFlags() {
-	flag.StringVar(&hcloudAPIToken, "hcloud-token", "", "the token to authenticate against the HCloud API")
-	flag.UintVar(&port, "port", defaultPort, "the port that the exporter exposes its data on")
-	flag.DurationVar(&fetchInterval, "fetch-interval", defaultFetchInterval, "the interval between data fetching cycles")
-	flag.StringVar(&additionalLabelsFlag, "additional-labels", "", "comma separated additional labels to parse for all metrics, e.g: 'service,environment,owner'")
-	flag.Parse()
+	tokens := tokenFlags{}
+	flags := newFlagSet(cfg, &tokens)
+	if err := applyEnvironment(flags); err != nil {
+		return nil, err
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
 
-	if hcloudAPIToken == "" {
-		if envHCloudAPIToken, present := os.LookupEnv("HCLOUD_TOKEN"); present {
-			hcloudAPIToken = envHCloudAPIToken
-		}
+	defaultProject, err := tokens.project()
+	if err != nil {
+		return nil, err
 	}
-	if hcloudAPIToken == "" {
-		panic("no API token for HCloud specified, but required")
+	switch {
+	case len(cfg.Projects) == 0 && defaultProject == nil:
+		return nil, errors.New("no API token for HCloud specified, but required")
+	case len(cfg.Projects) == 0:
+		cfg.Projects = []config.Project{*defaultProject}
+	case defaultProject != nil:
+		log.Println("Ignoring the HCloud API token, because the config file lists the projects to monitor")
 	}
 
-	additionalLabelsFlag = strings.TrimSpace(strings.ReplaceAll(additionalLabelsFlag, " ", ""))
-	additionalLabelsSlice := strings.Split(additionalLabelsFlag, ",")
-	if len(additionalLabelsSlice) > 0 && additionalLabelsSlice[0] != "" {
-		additionalLabels = additionalLabelsSlice
+	if err := cfg.Finalize(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
+	return cfg, nil
 }
 
-func main() {
-	handleFlags()
+// newFlagSet defines the command line flags, which default to and write into the passed configuration.
+func newFlagSet(cfg *config.Config, tokens *tokenFlags) *flag.FlagSet {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.String("config", "", "the path to a YAML or JSON config file, see config.example.yaml")
+	flags.StringVar(&tokens.token, "hcloud-token", "", "the token to authenticate against the HCloud API")
+	flags.StringVar(&tokens.tokenFile, "hcloud-token-file", "", "the path of a file that contains the token to authenticate against the HCloud API")
+	flags.BoolVar(&tokens.allowWorldReadable, "allow-world-readable-token-file", false, "accept a token file that can be read by every user")
+	flags.UintVar(&cfg.Port, "port", cfg.Port, "the port that the exporter exposes its data on")
+	flags.DurationVar(&cfg.Fetch.Interval, "fetch-interval", cfg.Fetch.Interval, "the interval between data fetching cycles")
+	flags.Var((*durationsFlag)(&cfg.Fetch.Intervals), "fetch-intervals", "comma separated intervals of single fetchers, e.g: 'snapshot=1h,primaryip=30m'")
+	flags.DurationVar(&cfg.Fetch.Timeout, "fetch-timeout", cfg.Fetch.Timeout, "the maximum duration of a single fetcher, 0 disables the timeout")
+	flags.DurationVar(&cfg.Fetch.CycleTimeout, "cycle-timeout", cfg.Fetch.CycleTimeout, "the maximum duration of a whole data fetching cycle, defaults to the fetch interval")
+	flags.IntVar(&cfg.Fetch.Concurrency, "fetch-concurrency", cfg.Fetch.Concurrency, "the maximum number of fetchers that run in parallel, 0 runs all of them at once")
+	flags.DurationVar(&cfg.Pricing.RefreshInterval, "pricing-refresh-interval", cfg.Pricing.RefreshInterval, "the interval in which the cached pricing is refreshed, defaults to ten fetch intervals")
+	flags.StringVar(&cfg.Pricing.File, "pricing-file", cfg.Pricing.File, "a local file in the shape of the /pricing response that is used instead of the API")
+	flags.StringVar((*string)(&cfg.Pricing.PriceType), "price-type", string(cfg.Pricing.PriceType), "the prices to expose, one of net, gross or both, which adds a price_type label")
+	flags.StringVar(&cfg.Pricing.Currency, "currency", cfg.Pricing.Currency, "the currency that all prices are converted to, e.g. USD, which requires -rates-file")
+	flags.StringVar(&cfg.Pricing.RatesFile, "rates-file", cfg.Pricing.RatesFile, "an XML or CSV export of the ECB reference rates that is used for the currency conversion")
+	flags.Var(&optionalFloatFlag{&cfg.Pricing.VATRate}, "vat-rate", "the VAT rate in percent that replaces the one of the pricing information, e.g. 0 for reverse charge")
+	flags.StringVar(&cfg.Pricing.Overrides, "pricing-overrides", cfg.Pricing.Overrides, "a local file in the shape of the /pricing response whose prices replace single prices")
+	flags.DurationVar(&cfg.Readiness.MaxAge, "ready-max-age", cfg.Readiness.MaxAge, "the maximum age of the last successful fetch before the exporter reports as not ready, defaults to three fetch intervals")
+	flags.IntVar(&cfg.RateLimit.Reserve, "ratelimit-reserve", cfg.RateLimit.Reserve, "the number of remaining API requests below which fetchers run sequentially and optional fetchers are skipped")
+	flags.Var((*listFlag)(&cfg.Fetchers.Enabled), "enabled-fetchers", "comma separated fetchers that run, defaults to all of them: "+strings.Join(fetcher.Names(), ","))
+	flags.Var((*listFlag)(&cfg.Fetchers.Disabled), "disabled-fetchers", "comma separated fetchers that do not run, even if they are enabled")
+	flags.Var((*listFlag)(&cfg.RateLimit.OptionalFetchers), "optional-fetchers", "comma separated fetchers that are skipped while the API request budget runs low")
+	flags.DurationVar(&cfg.Reload.Interval, "reload-interval", cfg.Reload.Interval, "the interval in which the config file and token files are checked for changes, 0 only reloads on SIGHUP")
+	flags.StringVar(&cfg.StateDir, "state-dir", cfg.StateDir, "the directory that keeps the last successful API responses, so that they are served right after a restart")
+	flags.Var((*listFlag)(&cfg.AdditionalLabels), "additional-labels", "comma separated additional labels to parse for all metrics, e.g: 'service,environment,owner'")
+	return flags
+}
 
-	client := hcloud.NewClient(hcloud.WithToken(hcloudAPIToken))
-	priceRepository := &fetcher.PriceProvider{Client: client}
+// tokenFlags describes the default project, which is monitored if the config file lists no projects.
+type tokenFlags struct {
+	token              string
+	tokenFile          string
+	allowWorldReadable bool
+}
 
-	fetchers := fetcher.Fetchers{
-		fetcher.NewFloatingIP(priceRepository, additionalLabels...),
-		fetcher.NewPrimaryIP(priceRepository, additionalLabels...),
-		fetcher.NewLoadbalancer(priceRepository, additionalLabels...),
-		fetcher.NewLoadbalancerTraffic(priceRepository, additionalLabels...),
-		fetcher.NewServer(priceRepository, additionalLabels...),
-		fetcher.NewServerBackup(priceRepository, additionalLabels...),
-		fetcher.NewServerTraffic(priceRepository, additionalLabels...),
-		fetcher.NewSnapshot(priceRepository, additionalLabels...),
-		fetcher.NewVolume(priceRepository, additionalLabels...),
+// project returns the default project or nil, if no token was passed. Flags take precedence over the HCLOUD_TOKEN and
+// HCLOUD_TOKEN_FILE environment variables.
+func (tokens tokenFlags) project() (*config.Project, error) {
+	if tokens.token != "" && tokens.tokenFile != "" {
+		return nil, errors.New("only one of -hcloud-token and -hcloud-token-file may be specified")
+	}
+	if tokens.token == "" && tokens.tokenFile == "" {
+		tokens.token = os.Getenv("HCLOUD_TOKEN")
+		if tokens.token == "" {
+			tokens.tokenFile = os.Getenv("HCLOUD_TOKEN_FILE")
+		}
+	}
+	if tokens.token == "" && tokens.tokenFile == "" {
+		return nil, nil //nolint:nilnil // no default project is not an error
 	}
 
-	fetchers.MustRun(client)
-	scheduler.RunTaskAtInterval(func() { fetchers.MustRun(client) }, fetchInterval, 0)
-	scheduler.RunTaskAtInterval(priceRepository.Sync, 10*fetchInterval, 10*fetchInterval)
+	return &config.Project{
+		Name:                        config.DefaultProjectName,
+		Token:                       tokens.token,
+		TokenFile:                   tokens.tokenFile,
+		AllowWorldReadableTokenFile: tokens.allowWorldReadable,
+	}, nil
+}
 
-	registry := prometheus.NewRegistry()
-	fetchers.RegisterCollectors(registry)
+// lookupConfigFile finds the path of the config file before the remaining flags are parsed, so that their defaults
+// can be taken from it. Malformed arguments are reported by flag.Parse later on.
+func lookupConfigFile(args []string) string {
+	configFile := os.Getenv(envPrefix + "CONFIG")
+	flags := newFlagSet(config.Default(), &tokenFlags{})
 
-	router := http.NewServeMux()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
 
-	router.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		if _, err := w.Write([]byte("ok")); err != nil {
-			log.Println(err)
+		// Flags without an inline value are followed by their value, unless they are boolean.
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !hasValue && !isBoolFlag(flags.Lookup(name)) && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if name == "config" {
+			configFile = value
+		}
+	}
+
+	return configFile
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	if f == nil {
+		return false
+	}
+
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// applyEnvironment sets every flag, for which an environment variable like HCLOUD_PRICING_FETCH_INTERVAL exists.
+func applyEnvironment(flags *flag.FlagSet) (err error) {
+	flags.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, present := os.LookupEnv(name); present && err == nil {
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+			}
 		}
 	})
+	return err
+}
+
+// listFlag is a flag.Value for comma separated lists.
+type listFlag []string
+
+func (list *listFlag) String() string {
+	if list == nil {
+		return ""
+	}
+	return strings.Join(*list, ",")
+}
+
+func (list *listFlag) Set(value string) error {
+	*list = splitList(value)
+	return nil
+}
+
+// optionalFloatFlag is a flag.Value for a number that is only set, if the flag is passed.
+type optionalFloatFlag struct {
+	value **float64
+}
+
+func (optional *optionalFloatFlag) String() string {
+	if optional == nil || optional.value == nil || *optional.value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(**optional.value, 'g', -1, 64)
+}
+
+func (optional *optionalFloatFlag) Set(value string) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*optional.value = &parsed
+	return nil
+}
+
+// durationsFlag is a flag.Value for comma separated durations by name.
+type durationsFlag map[string]time.Duration
+
+func (durations *durationsFlag) String() string {
+	if durations == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*durations))
+	for _, name := range slices.Sorted(maps.Keys(*durations)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, (*durations)[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (durations *durationsFlag) Set(value string) error {
+	parsed := map[string]time.Duration{}
+	for _, pair := range splitList(value) {
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not of the form name=duration", pair)
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		parsed[name] = duration
+	}
+
+	*durations = parsed
+	return nil
+}
+
+func splitList(list string) []string {
+	list = strings.TrimSpace(strings.ReplaceAll(list, " ", ""))
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func main() {
+	args := os.Args[1:]
+	cfg, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reloader, err := newReloader(ctx, args, cfg)
+	if err != nil {
+		panic(err)
+	}
+	go reloader.watch(ctx)
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      router,
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		Handler:      newRouter(reloader),
 		ReadTimeout:  defaultTimeout,
 		IdleTimeout:  defaultTimeout,
 		WriteTimeout: defaultTimeout,
 	}
 
-	log.Printf("Listening on: http://0.0.0.0:%d\n", port)
-	if err := server.ListenAndServe(); err != nil {
+	// ListenAndServe returns as soon as the shutdown begins, so in-flight scrapes and fetches are waited for here.
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+
+		<-ctx.Done()
+		log.Println("Shutting down, cancelling in-flight fetches...")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println(err)
+		}
+		reloader.wait()
+	}()
+
+	log.Printf("Listening on: http://0.0.0.0:%d\n", cfg.Port)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+	<-shutdown
+}
+
+// newRouter serves the metrics, the liveness and the readiness of the projects that the passed reloader monitors.
+func newRouter(reloader *reloader) *http.ServeMux {
+	router := http.NewServeMux()
+
+	router.Handle("/metrics", promhttp.HandlerFor(reloader, promhttp.HandlerOpts{}))
+	router.HandleFunc("/livez", handleLiveness)
+	router.HandleFunc("/health", handleLiveness)
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		handleReadiness(w, reloader)
+	})
+	return router
+}
+
+// projectsReadiness is the body of the readiness endpoint.
+type projectsReadiness struct {
+	Ready    bool                         `json:"ready"`
+	Projects map[string]fetcher.Readiness `json:"projects"`
+}
+
+// handleReadiness reports the exporter as ready, once all projects that the passed reloader monitors are ready.
+func handleReadiness(w http.ResponseWriter, reloader *reloader) {
+	cfg, projects := reloader.current()
+	readiness := projectsReadiness{
+		Ready:    true,
+		Projects: make(map[string]fetcher.Readiness, len(projects)),
+	}
+	for _, project := range projects {
+		projectReadiness := project.monitor.ReadinessFor(cfg.MaxAgeOf)
+		readiness.Projects[project.name] = projectReadiness
+		readiness.Ready = readiness.Ready && projectReadiness.Ready
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(readiness); err != nil {
+		log.Println(err)
+	}
+}
+
+func handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	if _, err := w.Write([]byte("ok")); err != nil {
+		log.Println(err)
+	}
 }
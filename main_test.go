@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeFile(name, content string) string {
+	path := filepath.Join(GinkgoT().TempDir(), name)
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	return path
+}
+
+var _ = Describe("For the configuration", func() {
+	const configFile = "port: 9001\nfetch:\n  interval: 2m\n"
+
+	DescribeTable("should let every source override the ones before it",
+		func(withFile bool, env map[string]string, flags []string, port uint, interval time.Duration) {
+			for name, value := range env {
+				GinkgoT().Setenv(name, value)
+			}
+
+			args := []string{"-hcloud-token", "token"}
+			if withFile {
+				args = append(args, "-config", writeFile("config.yaml", configFile))
+			}
+
+			cfg, err := loadConfig(append(args, flags...))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Port).To(Equal(port))
+			Expect(cfg.Fetch.Interval).To(Equal(interval))
+		},
+		Entry("defaults", false, nil, nil, uint(8080), time.Minute),
+		Entry("file over defaults", true, nil, nil, uint(9001), 2*time.Minute),
+		Entry("environment over file", true,
+			map[string]string{"HCLOUD_PRICING_PORT": "9002"}, nil, uint(9002), 2*time.Minute),
+		Entry("flags over environment", true,
+			map[string]string{"HCLOUD_PRICING_PORT": "9002", "HCLOUD_PRICING_FETCH_INTERVAL": "3m"},
+			[]string{"-port", "9003"}, uint(9003), 3*time.Minute),
+		Entry("flags over environment without file", false,
+			map[string]string{"HCLOUD_PRICING_FETCH_INTERVAL": "3m"},
+			[]string{"-fetch-interval", "4m"}, uint(8080), 4*time.Minute),
+	)
+
+	It("should read the config file from the environment", func() {
+		GinkgoT().Setenv("HCLOUD_PRICING_CONFIG", writeFile("config.yaml", configFile))
+
+		cfg, err := loadConfig([]string{"-hcloud-token", "token"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Port).To(Equal(uint(9001)))
+	})
+
+	It("should report invalid environment variables by name", func() {
+		GinkgoT().Setenv("HCLOUD_PRICING_FETCH_INTERVAL", "often")
+
+		_, err := loadConfig([]string{"-hcloud-token", "token"})
+		Expect(err).To(MatchError(ContainSubstring("HCLOUD_PRICING_FETCH_INTERVAL")))
+	})
+
+	DescribeTable("should find the config file before parsing the flags",
+		func(env string, args []string, expected string) {
+			if env != "" {
+				GinkgoT().Setenv("HCLOUD_PRICING_CONFIG", env)
+			}
+			Expect(lookupConfigFile(args)).To(Equal(expected))
+		},
+		Entry("without config", "", []string{"-port", "9090"}, ""),
+		Entry("separate value", "", []string{"-config", "a.yaml"}, "a.yaml"),
+		Entry("inline value", "", []string{"--config=a.yaml"}, "a.yaml"),
+		Entry("after a flag with a value", "", []string{"-port", "9090", "-config", "a.yaml"}, "a.yaml"),
+		Entry("after a bool flag", "", []string{"-allow-world-readable-token-file", "-config", "a.yaml"}, "a.yaml"),
+		Entry("after a bool flag with inline value", "",
+			[]string{"--allow-world-readable-token-file=true", "--config", "a.yaml"}, "a.yaml"),
+		Entry("as the value of another flag", "", []string{"-hcloud-token", "-config"}, ""),
+		Entry("after the terminator", "", []string{"--", "-config", "a.yaml"}, ""),
+		Entry("after a positional argument", "", []string{"positional", "-config", "a.yaml"}, ""),
+		Entry("from the environment", "b.yaml", []string{"-port", "9090"}, "b.yaml"),
+		Entry("flag over environment", "b.yaml", []string{"-config", "a.yaml"}, "a.yaml"),
+	)
+})
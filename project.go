@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/jangraefen/hcloud-pricing-exporter/config"
+	"github.com/jangraefen/hcloud-pricing-exporter/fetcher"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// project bundles everything that is needed to monitor the expenses of a single HCloud project.
+type project struct {
+	name        string
+	client      *hcloud.Client
+	pricing     *fetcher.PriceProvider
+	fetchers    fetcher.Fetchers
+	monitor     *fetcher.Monitor
+	rateLimiter *fetcher.RateLimiter
+	state       *fetcher.StateStore
+	runOpts     fetcher.RunOptions
+	fetch       config.Fetch
+	refresh     time.Duration
+	registry    *prometheus.Registry
+	cancel      context.CancelFunc
+	cycles      func()
+}
+
+func newProject(cfg *config.Config, projectConfig config.Project, token string) (*project, error) {
+	additionalLabels := projectConfig.Labels(cfg)
+	rateLimiter := fetcher.NewRateLimiter(http.DefaultTransport, cfg.RateLimit.Reserve)
+	client := hcloud.NewClient(
+		hcloud.WithToken(token),
+		hcloud.WithHTTPClient(&http.Client{Transport: rateLimiter}),
+	)
+
+	var state *fetcher.StateStore
+	var err error
+	if dir := projectConfig.StateDir(cfg); dir != "" {
+		if state, err = fetcher.NewStateStore(dir); err != nil {
+			return nil, fmt.Errorf("project %s: %w", projectConfig.Name, err)
+		}
+	}
+
+	priceRepository, err := newPriceProvider(cfg, projectConfig, client, state)
+	if err != nil {
+		return nil, err
+	}
+	monitor := fetcher.NewMonitor(priceRepository)
+
+	names, err := projectConfig.FetcherNames(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fetchers, err := fetcher.New(names, priceRepository, additionalLabels...)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &project{
+		name:        projectConfig.Name,
+		client:      client,
+		pricing:     priceRepository,
+		fetchers:    fetchers,
+		monitor:     monitor,
+		state:       state,
+		rateLimiter: rateLimiter,
+		runOpts: fetcher.RunOptions{
+			FetchTimeout: cfg.Fetch.Timeout,
+			CycleTimeout: cfg.Fetch.CycleTimeout,
+			Concurrency:  cfg.Fetch.Concurrency,
+			Monitor:      monitor,
+			RateLimiter:  rateLimiter,
+			Optional:     cfg.RateLimit.OptionalFetchers,
+			State:        state,
+		},
+		fetch:    cfg.Fetch,
+		refresh:  cfg.Pricing.RefreshInterval,
+		registry: prometheus.NewRegistry(),
+	}
+
+	if err := project.register(); err != nil {
+		return nil, fmt.Errorf("project %s: %w", project.name, err)
+	}
+	return project, nil
+}
+
+// newPriceProvider creates the provider of the prices of a project, along with the files it reads them from.
+func newPriceProvider(
+	cfg *config.Config, projectConfig config.Project, client *hcloud.Client, state *fetcher.StateStore,
+) (*fetcher.PriceProvider, error) {
+	var err error
+	priceRepository := &fetcher.PriceProvider{
+		Client:    client,
+		State:     state,
+		PriceType: cfg.Pricing.PriceType,
+		Currency:  cfg.Pricing.Currency,
+		VATRate:   projectConfig.VATRateOf(cfg),
+	}
+	for _, adjustment := range cfg.Pricing.Adjustments {
+		priceRepository.Adjustments = append(priceRepository.Adjustments, fetcher.Adjustment(adjustment))
+	}
+	if cfg.Pricing.File != "" {
+		if priceRepository.File, err = fetcher.NewPricingFile(cfg.Pricing.File); err != nil {
+			return nil, fmt.Errorf("pricing.file: %w", err)
+		}
+	}
+	if cfg.Pricing.RatesFile != "" {
+		if priceRepository.Rates, err = fetcher.NewRatesFile(cfg.Pricing.RatesFile); err != nil {
+			return nil, fmt.Errorf("pricing.rates_file: %w", err)
+		}
+	}
+	if cfg.Pricing.Overrides != "" {
+		if priceRepository.Overrides, err = fetcher.NewPricingFile(cfg.Pricing.Overrides); err != nil {
+			return nil, fmt.Errorf("pricing.overrides: %w", err)
+		}
+	}
+	return priceRepository, nil
+}
+
+// register registers all collectors of the project into its registry. All collectors are labeled with the name of
+// the project.
+func (project *project) register() error {
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{config.ProjectLabel: project.name}, project.registry)
+	if err := project.fetchers.RegisterCollectors(registerer); err != nil {
+		return err
+	}
+	for _, collector := range []prometheus.Collector{project.monitor, project.rateLimiter} {
+		if err := registerer.Register(collector); err != nil {
+			return fmt.Errorf("failed to register the collector: %w", err)
+		}
+	}
+	return nil
+}
+
+// inherit takes over the exposed data of the project that is replaced by this one.
+func (project *project) inherit(previous *project) {
+	project.fetchers.Inherit(previous.fetchers)
+	project.monitor.Inherit(previous.monitor, project.fetchers)
+}
+
+// restore exposes the costs that result from the persisted state of the project as stale, until the first data
+// fetching cycle completes. Nothing is exposed, if the pricing or the resources were not persisted. The costs accrued
+// in the current month are restored in any case.
+func (project *project) restore(ctx context.Context) {
+	if project.state == nil {
+		return
+	}
+
+	if err := project.fetchers.RestoreAccrued(project.state); err != nil {
+		log.Printf("Failed to restore accrued costs of project %s: %v", project.name, err)
+	}
+	if ok, err := project.pricing.Restore(); err != nil || !ok {
+		if err != nil {
+			log.Printf("Failed to restore pricing of project %s: %v", project.name, err)
+		}
+		return
+	}
+	inventory, ok, err := fetcher.RestoreInventory(project.state)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Failed to restore resources of project %s: %v", project.name, err)
+		}
+		return
+	}
+
+	log.Printf("Serving persisted costs of project %s until the first data fetching cycle completes", project.name)
+	project.fetchers.Restore(ctx, inventory)
+}
+
+// start runs a first data fetching cycle right away and repeats it for every fetcher in its configured interval, until
+// the project is stopped or the passed context is done.
+func (project *project) start(ctx context.Context) {
+	ctx, project.cancel = context.WithCancel(ctx)
+	project.cycles = project.fetchers.Schedule(ctx, project.client, project.fetch.IntervalOf, project.runOpts)
+
+	go func() {
+		ticker := time.NewTicker(project.refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				project.pricing.Sync()
+			}
+		}
+	}()
+}
+
+// stop cancels the data fetching cycles of the project, including the one in-flight.
+func (project *project) stop() {
+	if project.cancel != nil {
+		project.cancel()
+	}
+}
+
+// wait blocks until the data fetching cycles of a stopped project finished.
+func (project *project) wait() {
+	if project.cycles != nil {
+		project.cycles()
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jangraefen/hcloud-pricing-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// reloader owns the monitored projects and replaces them, whenever the config file or a token file changes or the
+// exporter receives SIGHUP. It gathers the metrics of the current projects, so that a reload never interrupts them.
+type reloader struct {
+	ctx  context.Context
+	args []string
+
+	reloadLock   sync.Mutex
+	fingerprints map[string]string
+
+	projectsLock sync.RWMutex
+	cfg          *config.Config
+	projects     []*project
+
+	registry    *prometheus.Registry
+	reloads     *prometheus.CounterVec
+	lastReload  prometheus.Gauge
+	lastSuccess prometheus.Gauge
+}
+
+func newReloader(ctx context.Context, args []string, cfg *config.Config) (*reloader, error) {
+	reloader := &reloader{
+		ctx:      ctx,
+		args:     args,
+		registry: prometheus.NewRegistry(),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "config_reloads_total",
+			Help:      "The number of attempted configuration reloads per result",
+		}, []string{"result"}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload succeeded",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "hcloud",
+			Subsystem: "pricing_exporter",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "The point in time of the last successful configuration reload",
+		}),
+	}
+	reloader.registry.MustRegister(reloader.reloads, reloader.lastReload, reloader.lastSuccess)
+	// Touch both results, so that they are exposed with zero before the first reload.
+	reloader.reloads.WithLabelValues("success")
+	reloader.reloads.WithLabelValues("failure")
+
+	projects, err := buildProjects(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		project.restore(ctx)
+	}
+
+	reloader.swap(cfg, projects)
+	reloader.fingerprints = fingerprint(reloader.watchedFiles(cfg))
+	reloader.lastReload.Set(1)
+	reloader.lastSuccess.SetToCurrentTime()
+	return reloader, nil
+}
+
+// Gather implements prometheus.Gatherer.
+func (reloader *reloader) Gather() ([]*dto.MetricFamily, error) {
+	_, projects := reloader.current()
+
+	gatherers := prometheus.Gatherers{reloader.registry}
+	for _, project := range projects {
+		gatherers = append(gatherers, project.registry)
+	}
+	return gatherers.Gather()
+}
+
+// current returns the configuration and the projects that are currently monitored.
+func (reloader *reloader) current() (*config.Config, []*project) {
+	reloader.projectsLock.RLock()
+	defer reloader.projectsLock.RUnlock()
+
+	return reloader.cfg, reloader.projects
+}
+
+// wait blocks until the data fetching cycles of all current projects finished, once the context of the reloader is
+// done.
+func (reloader *reloader) wait() {
+	_, projects := reloader.current()
+	for _, project := range projects {
+		project.wait()
+	}
+}
+
+// watch reloads the configuration on SIGHUP and whenever one of the watched files changes, until the passed context
+// is done.
+func (reloader *reloader) watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	resetTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+			tick = nil
+		}
+
+		if cfg, _ := reloader.current(); cfg.Reload.Interval > 0 {
+			ticker = time.NewTicker(cfg.Reload.Interval)
+			tick = ticker.C
+		}
+	}
+	resetTicker()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Println("Received SIGHUP, reloading configuration...")
+		case <-tick:
+			if !reloader.changed() {
+				continue
+			}
+			log.Println("Configuration or token files changed, reloading configuration...")
+		}
+
+		if err := reloader.reload(); err != nil {
+			log.Printf("Failed to reload configuration, keeping the previous one: %v", err)
+		}
+		resetTicker()
+	}
+}
+
+// changed reports whether any of the watched files changed since the last reload.
+func (reloader *reloader) changed() bool {
+	reloader.reloadLock.Lock()
+	defer reloader.reloadLock.Unlock()
+
+	cfg, _ := reloader.current()
+	return !maps.Equal(reloader.fingerprints, fingerprint(reloader.watchedFiles(cfg)))
+}
+
+// reload builds new projects from the current configuration and replaces the running ones with them. Failed reloads
+// keep the running projects and are only retried once the watched files change again.
+func (reloader *reloader) reload() error {
+	reloader.reloadLock.Lock()
+	defer reloader.reloadLock.Unlock()
+
+	cfg, err := reloader.apply()
+	reloader.fingerprints = fingerprint(reloader.watchedFiles(cfg))
+	if err != nil {
+		reloader.reloads.WithLabelValues("failure").Inc()
+		reloader.lastReload.Set(0)
+		return err
+	}
+
+	reloader.reloads.WithLabelValues("success").Inc()
+	reloader.lastReload.Set(1)
+	reloader.lastSuccess.SetToCurrentTime()
+	log.Printf("Reloaded configuration, monitoring %d project(s)", len(cfg.Projects))
+	return nil
+}
+
+// apply replaces the running projects with new ones and returns the configuration that is in effect afterwards. The
+// new projects take over the data of their predecessors, so that no metrics disappear until their first data fetching
+// cycle ends.
+func (reloader *reloader) apply() (*config.Config, error) {
+	previousConfig, previousProjects := reloader.current()
+
+	cfg, err := loadConfig(reloader.args)
+	if err != nil {
+		return previousConfig, err
+	}
+	projects, err := buildProjects(cfg)
+	if err != nil {
+		return previousConfig, err
+	}
+
+	if cfg.Port != previousConfig.Port {
+		log.Printf("Changing the port requires a restart, still listening on %d", previousConfig.Port)
+	}
+
+	for _, project := range projects {
+		inherited := false
+		for _, previous := range previousProjects {
+			if previous.name == project.name {
+				project.inherit(previous)
+				inherited = true
+			}
+		}
+		if !inherited {
+			// Projects that were just added start from their persisted state instead.
+			project.restore(reloader.ctx)
+		}
+	}
+
+	reloader.swap(cfg, projects)
+	for _, previous := range previousProjects {
+		previous.stop()
+	}
+	return cfg, nil
+}
+
+// swap replaces the current projects and starts the new ones.
+func (reloader *reloader) swap(cfg *config.Config, projects []*project) {
+	reloader.projectsLock.Lock()
+	reloader.cfg = cfg
+	reloader.projects = projects
+	reloader.projectsLock.Unlock()
+
+	for _, project := range projects {
+		project.start(reloader.ctx)
+	}
+}
+
+func (reloader *reloader) watchedFiles(cfg *config.Config) []string {
+	files := cfg.WatchedFiles()
+	if configFile := lookupConfigFile(reloader.args); configFile != "" {
+		files = append(files, configFile)
+	}
+	return files
+}
+
+// buildProjects creates the monitored projects from the configuration.
+func buildProjects(cfg *config.Config) ([]*project, error) {
+	projects := make([]*project, 0, len(cfg.Projects))
+	for _, projectConfig := range cfg.Projects {
+		token, err := projectConfig.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		project, err := newProject(cfg, projectConfig, token)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// fingerprint hashes the contents of the passed files. Files that cannot be read are recorded with their error, so
+// that they count as changed once they can be read again.
+func fingerprint(files []string) map[string]string {
+	fingerprints := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fingerprints[file] = err.Error()
+			continue
+		}
+		fingerprints[file] = fmt.Sprintf("%x", sha256.Sum256(content))
+	}
+	return fingerprints
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"os"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("For configuration reloads", func() {
+	var (
+		configFile string
+		sut        *reloader
+	)
+
+	// start monitors the projects of the passed config file. The context is done right away, so that the first data
+	// fetching cycles fail without reaching the HCloud API.
+	start := func(content string) {
+		configFile = writeFile("config.yaml", content)
+		args := []string{"-config", configFile}
+		cfg, err := loadConfig(args)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		sut, err = newReloader(ctx, args, cfg)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() { sut.wait() })
+	}
+
+	rewrite := func(path, content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	}
+
+	projectNames := func() []string {
+		families, err := sut.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		names := map[string]bool{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "project" {
+						names[label.GetValue()] = true
+					}
+				}
+			}
+		}
+		return slices.Sorted(maps.Keys(names))
+	}
+
+	It("should rebuild the projects, if a token file changed", func() {
+		tokenFile := writeFile("token", "old-token")
+		start("projects:\n  - name: a\n    token_file: " + tokenFile + "\n")
+		_, previous := sut.current()
+		Expect(sut.changed()).To(BeFalse())
+
+		rewrite(tokenFile, "new-token")
+		Expect(sut.changed()).To(BeTrue())
+		Expect(sut.reload()).To(Succeed())
+
+		_, projects := sut.current()
+		Expect(projects).To(HaveLen(1))
+		Expect(projects[0]).NotTo(BeIdenticalTo(previous[0]))
+		Expect(sut.changed()).To(BeFalse())
+		Expect(testutil.ToFloat64(sut.reloads.WithLabelValues("success"))).To(Equal(1.0))
+	})
+
+	It("should keep the previous projects, if the reload fails", func() {
+		start("projects:\n  - name: a\n    token: token\n")
+		_, previous := sut.current()
+
+		rewrite(configFile, "projects:\n  - name: a\n    token: token\nfetch:\n  interval: -1m\n")
+		Expect(sut.reload()).To(MatchError(ContainSubstring("fetch.interval")))
+
+		_, projects := sut.current()
+		Expect(projects).To(Equal(previous))
+		Expect(testutil.ToFloat64(sut.reloads.WithLabelValues("failure"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(sut.reloads.WithLabelValues("success"))).To(BeZero())
+		Expect(testutil.ToFloat64(sut.lastReload)).To(BeZero())
+		Expect(sut.changed()).To(BeFalse())
+	})
+
+	It("should keep the previous projects, if the new metrics cannot be registered", func() {
+		start("projects:\n  - name: a\n    token: token\n")
+		_, previous := sut.current()
+
+		rewrite(configFile, "projects:\n  - name: a\n    token: token\nadditional_labels: [location]\n")
+		Expect(sut.reload()).NotTo(Succeed())
+
+		_, projects := sut.current()
+		Expect(projects).To(Equal(previous))
+		Expect(testutil.ToFloat64(sut.reloads.WithLabelValues("failure"))).To(Equal(1.0))
+	})
+
+	It("should stop exposing removed projects", func() {
+		start("projects:\n  - name: a\n    token: token\n  - name: b\n    token: token\n")
+		Expect(projectNames()).To(Equal([]string{"a", "b"}))
+
+		rewrite(configFile, "projects:\n  - name: a\n    token: token\n")
+		Expect(sut.reload()).To(Succeed())
+
+		Expect(projectNames()).To(Equal([]string{"a"}))
+	})
+})